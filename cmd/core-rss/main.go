@@ -1,15 +1,20 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
+	"github.com/rzinak/core-rss/internal/config"
 	"github.com/rzinak/core-rss/internal/models"
 	"github.com/rzinak/core-rss/internal/services"
 	"github.com/rzinak/core-rss/internal/ui"
+	"os"
+	"strings"
 )
 
 func main() {
-	folderData, err := services.LoadFolders()
+	folderData, foldersErr := services.LoadFolders()
 
-	if err != nil {
+	if foldersErr != nil {
 		folderData = &models.FolderData{
 			Folders: []models.FeedFolder{{
 				Name:  "Default",
@@ -18,5 +23,116 @@ func main() {
 		}
 	}
 
-	ui.SetupUI(folderData)
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error loading config.json, using the defaults:", err)
+	}
+	if foldersErr != nil {
+		// without the real list of feeds every cached item looks like it's
+		// from a feed we unsubscribed from, so nothing gets purged
+		cfg.CleanupIntervalMinutes = 0
+	}
+
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "vacuum":
+			if foldersErr != nil {
+				fmt.Fprintln(os.Stderr, "Error loading feeds.json, not cleaning up the cache:", foldersErr)
+				os.Exit(1)
+			}
+			vacuum(folderData, cfg)
+			return
+		case "scrape":
+			scrape(folderData, os.Args[2:])
+			return
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown command: %s\n", os.Args[1])
+			fmt.Fprintln(os.Stderr, "Usage: core-rss [vacuum | scrape <url> [item=<selector> title=... link=... date=... content=...]]")
+			os.Exit(2)
+		}
+	}
+
+	ui.SetupUI(folderData, cfg, foldersErr == nil)
+}
+
+// vacuum applies the retention policies to the cache and shows what was removed
+func vacuum(folderData *models.FolderData, cfg *config.Config) {
+	report, err := services.VacuumCache(folderData, cfg.Retention)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error cleaning up the cache:", err)
+		os.Exit(1)
+	}
+
+	for feed, removed := range report.PerFeed {
+		fmt.Printf("%-50s %d items removed\n", feed, removed)
+	}
+	fmt.Printf("\nRemoved %d items, cache went from %d KB to %d KB (%d KB reclaimed)\n",
+		len(report.Removed), report.BytesBefore/1024, report.BytesAfter/1024, (report.BytesBefore-report.BytesAfter)/1024)
+}
+
+// scrape shows what a scrape rule gets from a page, to try selectors before
+// putting them in feeds.json. Without selectors the rule of the feed with
+// that url is used
+func scrape(folderData *models.FolderData, args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: core-rss scrape <url> [item=<selector> title=... link=... date=... content=...]")
+		os.Exit(2)
+	}
+
+	feed := &models.Feed{URL: args[0], Source: "scrape"}
+	if len(args) == 1 {
+		for _, folder := range folderData.Folders {
+			for _, existing := range folder.Feeds {
+				if existing.URL == args[0] && existing.Scrape != nil {
+					feed.Scrape, feed.Auth = existing.Scrape, existing.Auth
+				}
+			}
+		}
+		if feed.Scrape == nil {
+			fmt.Fprintln(os.Stderr, "No scrape rule for", args[0], "in feeds.json, pass the selectors (item=...)")
+			os.Exit(2)
+		}
+	} else {
+		feed.Scrape = &models.ScrapeRule{}
+		for _, arg := range args[1:] {
+			name, selector, ok := strings.Cut(arg, "=")
+			if !ok {
+				fmt.Fprintf(os.Stderr, "Expected name=selector, got %q\n", arg)
+				os.Exit(2)
+			}
+			switch name {
+			case "item":
+				feed.Scrape.Item = selector
+			case "title":
+				feed.Scrape.Title = selector
+			case "link":
+				feed.Scrape.Link = selector
+			case "date":
+				feed.Scrape.Date = selector
+			case "content":
+				feed.Scrape.Content = selector
+			default:
+				fmt.Fprintf(os.Stderr, "Unknown field %q, use item, title, link, date or content\n", name)
+				os.Exit(2)
+			}
+		}
+	}
+
+	fetched, err := services.FetchFeed(feed)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("%s: %d items\n\n", fetched.Title, len(fetched.Items))
+	for i, item := range fetched.Items {
+		fmt.Printf("%d. %s\n   link: %s\n   date: %s\n   content: %d bytes\n", i+1, item.Title, item.Link, item.PubDate, len(item.Content))
+	}
+
+	feed.Title = fetched.Title
+	fmt.Println("\nAdd it to a folder in feeds.json:")
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetEscapeHTML(false) // selectors are full of >
+	encoder.SetIndent("", "    ")
+	encoder.Encode(feed)
 }
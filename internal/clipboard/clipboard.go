@@ -0,0 +1,67 @@
+package clipboard
+
+import (
+	"encoding/base64"
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// terminals ignore OSC 52 sequences bigger than this (or worse), longer
+// texts only go through the clipboard programs
+const maxOSC52Size = 74994
+
+// Copy puts text in the system clipboard. It uses the OSC 52 escape
+// sequence, sent through screen so it doesn't end up in the middle of a
+// draw, so the terminal does the copying and it works over ssh, and when
+// we're running locally it also hands the text to wl-copy/xclip/xsel/pbcopy
+// if one of them is installed, since not every terminal supports OSC 52
+func Copy(screen tcell.Screen, text string) error {
+	oscErr := copyOSC52(screen, text)
+
+	if os.Getenv("SSH_TTY") == "" && os.Getenv("SSH_CONNECTION") == "" {
+		if name, args, ok := clipboardCommand(); ok {
+			cmdErr := copyCommand(text, name, args...)
+			if cmdErr == nil || oscErr == nil {
+				return nil
+			}
+			return cmdErr
+		}
+	}
+	return oscErr
+}
+
+func copyOSC52(screen tcell.Screen, text string) error {
+	if base64.StdEncoding.EncodedLen(len(text)) > maxOSC52Size {
+		return fmt.Errorf("text is too long to be copied through the terminal")
+	}
+	screen.SetClipboard([]byte(text))
+	return nil
+}
+
+// clipboardCommand finds a program that can write to the clipboard
+func clipboardCommand() (string, []string, bool) {
+	candidates := [][]string{}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		candidates = append(candidates, []string{"wl-copy"})
+	}
+	if os.Getenv("DISPLAY") != "" {
+		candidates = append(candidates, []string{"xclip", "-selection", "clipboard"}, []string{"xsel", "--clipboard", "--input"})
+	}
+	candidates = append(candidates, []string{"pbcopy"})
+
+	for _, candidate := range candidates {
+		if _, err := exec.LookPath(candidate[0]); err == nil {
+			return candidate[0], candidate[1:], true
+		}
+	}
+	return "", nil, false
+}
+
+func copyCommand(text string, name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = strings.NewReader(text)
+	return cmd.Run()
+}
@@ -0,0 +1,141 @@
+package config
+
+import (
+	"encoding/json"
+	"github.com/rzinak/core-rss/internal/models"
+	"os"
+	"path/filepath"
+)
+
+// Config holds the user settings, kept in config.json next to feeds.json
+type Config struct {
+	Retention              models.Retention `json:"retention"`
+	CleanupIntervalMinutes int              `json:"cleanupIntervalMinutes"`
+	Rules                  []Rule           `json:"rules"`
+	DedupByTitle           bool             `json:"dedupByTitle"` // also merge items with the same title in merged views
+	Openers                []OpenerRule     `json:"openers"`
+	ImageProtocol          string           `json:"imageProtocol"`          // auto, kitty, sixel or none
+	DownloadDir            string           `json:"downloadDir"`            // where enclosures (podcasts) are saved
+	Player                 string           `json:"player"`                 // command to play enclosures, {url} is the file or url
+	RefreshIntervalMinutes int              `json:"refreshIntervalMinutes"` // refresh every feed in the background, 0 turns it off
+	Notifications          Notifications    `json:"notifications"`
+	UpdateMovedFeeds       string           `json:"updateMovedFeeds"` // auto, ask or never
+}
+
+// Notifications says which new items we notify about. Feeds can also be
+// picked one by one (the notify flag in feeds.json) and rules can use the
+// notify action
+type Notifications struct {
+	All             bool     `json:"all"`             // every feed
+	Folders         []string `json:"folders"`         // every feed in these folders
+	IntervalSeconds int      `json:"intervalSeconds"` // at most one notification in this time, the rest are summed up
+}
+
+// OpenerRule sends the urls matching Pattern (a regex) to Command instead of
+// the browser, {url} in the command is replaced by the url
+type OpenerRule struct {
+	Pattern string `json:"pattern"`
+	Command string `json:"command"`
+}
+
+// Rule matches items by one of their fields and does something with them.
+// Rules without a folder or feed apply to every feed
+type Rule struct {
+	Field  string `json:"field"` // title, author, category, link, content or any
+	Match  string `json:"match"` // a case insensitive substring, or a regex if Regex is set
+	Regex  bool   `json:"regex,omitempty"`
+	Folder string `json:"folder,omitempty"` // folder name
+	Feed   string `json:"feed,omitempty"`   // feed url
+	Action string `json:"action"`           // hide, read, star, highlight or notify
+	Color  string `json:"color,omitempty"`  // for highlight, any color name tview knows
+}
+
+func Default() *Config {
+	home, _ := os.UserHomeDir()
+	return &Config{
+		Retention: models.Retention{
+			MaxItems:   500,
+			MaxAgeDays: 90,
+			KeepUnread: true,
+		},
+		CleanupIntervalMinutes: 60,
+		Rules:                  []Rule{},
+		Openers:                []OpenerRule{},
+		ImageProtocol:          "auto",
+		DownloadDir:            filepath.Join(home, "Downloads", "core-rss"),
+		RefreshIntervalMinutes: 30,
+		UpdateMovedFeeds:       "auto",
+		Notifications: Notifications{
+			Folders:         []string{},
+			IntervalSeconds: 60,
+		},
+	}
+}
+
+// Dir returns the directory where core-rss keeps its files (feeds, cache, ...),
+// creating it if it doesn't exist yet
+func Dir() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	appDir := filepath.Join(configDir, "core-rss")
+	if err := os.MkdirAll(appDir, 0755); err != nil {
+		return "", err
+	}
+
+	return appDir, nil
+}
+
+// Path returns the full path of a file inside the app directory
+func Path(name string) (string, error) {
+	appDir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(appDir, name), nil
+}
+
+// Load reads config.json, settings missing from the file keep their default
+// value. If there's no file yet one is written with the defaults so it's
+// easier to find out what can be changed
+func Load() (*Config, error) {
+	cfg := Default()
+
+	filePath, err := Path("config.json")
+	if err != nil {
+		return cfg, err
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, Save(cfg)
+		}
+		return cfg, err
+	}
+	defer file.Close()
+
+	if err := json.NewDecoder(file).Decode(cfg); err != nil {
+		return Default(), err
+	}
+	return cfg, nil
+}
+
+func Save(cfg *Config) error {
+	filePath, err := Path("config.json")
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "    ")
+	return encoder.Encode(cfg)
+}
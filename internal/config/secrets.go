@@ -0,0 +1,106 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// the passwords and tokens of authenticated feeds are kept in secrets.json,
+// away from feeds.json and readable only by the user
+
+// LoadSecrets reads secrets.json, a missing file is just no secrets
+func LoadSecrets() (map[string]string, error) {
+	secrets := map[string]string{}
+
+	filePath, err := Path("secrets.json")
+	if err != nil {
+		return secrets, err
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return secrets, nil
+		}
+		return secrets, err
+	}
+
+	if err := json.Unmarshal(data, &secrets); err != nil {
+		return map[string]string{}, err
+	}
+	return secrets, nil
+}
+
+// SaveSecret stores a secret under name in secrets.json
+func SaveSecret(name, value string) error {
+	secrets, err := LoadSecrets()
+	if err != nil {
+		return err
+	}
+	secrets[name] = value
+	return saveSecrets(secrets)
+}
+
+// DeleteSecret removes the secret named name from secrets.json, if it's there
+func DeleteSecret(name string) error {
+	secrets, err := LoadSecrets()
+	if err != nil {
+		return err
+	}
+	if _, ok := secrets[name]; !ok {
+		return nil
+	}
+	delete(secrets, name)
+	return saveSecrets(secrets)
+}
+
+func saveSecrets(secrets map[string]string) error {
+	filePath, err := Path("secrets.json")
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(secrets, "", "    ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filePath, data, 0600); err != nil {
+		return err
+	}
+	// WriteFile keeps the permissions of a file that already existed
+	return os.Chmod(filePath, 0600)
+}
+
+// ResolveSecret turns a secret reference into its value: "env:NAME" reads an
+// environment variable, "cmd:..." runs a command (a password manager, say)
+// and uses what it prints, anything else is a name in secrets.json
+func ResolveSecret(ref string) (string, error) {
+	switch {
+	case strings.HasPrefix(ref, "env:"):
+		name := strings.TrimPrefix(ref, "env:")
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return value, nil
+	case strings.HasPrefix(ref, "cmd:"):
+		out, err := exec.Command("sh", "-c", strings.TrimPrefix(ref, "cmd:")).Output()
+		if err != nil {
+			return "", fmt.Errorf("running the secret command: %v", err)
+		}
+		return strings.TrimRight(string(out), "\r\n"), nil
+	}
+
+	secrets, err := LoadSecrets()
+	if err != nil {
+		return "", err
+	}
+	value, ok := secrets[ref]
+	if !ok {
+		return "", fmt.Errorf("no secret named %q in secrets.json", ref)
+	}
+	return value, nil
+}
@@ -2,21 +2,128 @@ package models
 
 import (
 	"github.com/rivo/tview"
+	"time"
 )
 
 type Item struct {
-	Title       string `xml:"title"`
-	Description string `xml:"description"`
-	Link        string `xml:"link"`
-	PubDate     string `xml:"pubDate"`
-	Content     string `xml:"http://purl.org/rss/1.0/modules/content/ encoded"` // gotta map <content:encoded>
+	Title       string `xml:"title" json:"title"`
+	Description string `xml:"description" json:"description,omitempty"`
+	Link        string `xml:"link" json:"link"`
+	PubDate     string `xml:"pubDate" json:"pubDate"`
+	Content     string `xml:"http://purl.org/rss/1.0/modules/content/ encoded" json:"content,omitempty"` // gotta map <content:encoded>
 	// Content string `xml:"content:encoded" xml_namespace:"http://purl.org/rss/1.0/modules/content/"`
+	GUID       string      `xml:"guid" json:"guid,omitempty"`
+	Author     string      `xml:"author" json:"author,omitempty"`
+	Creator    string      `xml:"http://purl.org/dc/elements/1.1/ creator" json:"creator,omitempty"` // <dc:creator>, used a lot instead of <author>
+	Categories []string    `xml:"category" json:"categories,omitempty"`
+	Enclosures []Enclosure `xml:"enclosure" json:"enclosures,omitempty"`
+	Duration   string      `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration" json:"duration,omitempty"` // <itunes:duration>
+
+	// these are not part of the rss, they're filled when the item is cached
+	FeedURL   string `xml:"-" json:"feedUrl"`
+	FeedTitle string `xml:"-" json:"feedTitle"`
+	Read      bool   `xml:"-" json:"read,omitempty"`
+	Starred   bool   `xml:"-" json:"starred,omitempty"`
+
+	// the article extracted from the item's link, for feeds that only have summaries
+	FullContent string `xml:"-" json:"fullContent,omitempty"`
+
+	// the same article found in other feeds, only filled in merged views
+	Duplicates []Item `xml:"-" json:"-"`
+}
+
+// Enclosure is a media file attached to an item, podcast episodes mostly
+type Enclosure struct {
+	URL    string `xml:"url,attr" json:"url"`
+	Length string `xml:"length,attr" json:"length,omitempty"` // in bytes, kept as text since feeds put all kinds of junk here
+	Type   string `xml:"type,attr" json:"type,omitempty"`
 }
 
 type Feed struct {
-	Title string `xml:"channel>title" json:"title"`
-	URL   string `json:"url"`
-	Items []Item `xml:"channel>item" json:"-"`
+	Title     string      `xml:"channel>title" json:"title"`
+	URL       string      `json:"url"`
+	Source    string      `xml:"-" json:"source,omitempty"` // http, file, command, filter, scrape, gemini or gopher, guessed from the url when empty
+	Items     []Item      `xml:"channel>item" json:"-"`
+	Retention *Retention  `xml:"-" json:"retention,omitempty"` // overrides the global one
+	FullText  bool        `xml:"-" json:"fullText,omitempty"`  // load the full article when an item is opened
+	Notify    bool        `xml:"-" json:"notify,omitempty"`    // send a notification for new items
+	Status    *FeedStatus `xml:"-" json:"status,omitempty"`
+	Auth      *FeedAuth   `xml:"-" json:"auth,omitempty"`
+	Scrape    *ScrapeRule `xml:"-" json:"scrape,omitempty"` // for the scrape source
+
+	// how often the publisher wants to be polled, kept from the last fetch
+	TTL             string   `xml:"channel>ttl" json:"ttl,omitempty"`                   // minutes
+	SkipHours       []string `xml:"channel>skipHours>hour" json:"skipHours,omitempty"`  // 0-23, in GMT
+	SkipDays        []string `xml:"channel>skipDays>day" json:"skipDays,omitempty"`     // Monday, Tuesday...
+	UpdatePeriod    string   `xml:"channel>updatePeriod" json:"updatePeriod,omitempty"` // sy:updatePeriod, hourly, daily...
+	UpdateFrequency string   `xml:"channel>updateFrequency" json:"updateFrequency,omitempty"`
+
+	// <atom:link> elements, plain rss <link>s end up here too without a rel
+	Links []AtomLink `xml:"channel>link" json:"-"`
+	// set when a fetch finds out the feed lives somewhere else
+	Move *FeedMove `xml:"-" json:"-"`
+}
+
+// FeedAuth says how to authenticate to a feed. Secrets are references: a
+// name in secrets.json, "env:NAME" or "cmd:command", never the secret itself
+type FeedAuth struct {
+	Type          string            `json:"type,omitempty"` // basic or bearer, empty when only headers or a certificate are needed
+	Username      string            `json:"username,omitempty"`
+	Secret        string            `json:"secret,omitempty"`        // the password or token
+	Headers       map[string]string `json:"headers,omitempty"`       // sent as they are
+	SecretHeaders map[string]string `json:"secretHeaders,omitempty"` // header -> secret reference, for cookies and api keys
+	Cert          string            `json:"cert,omitempty"`          // client tls certificate (pem file)
+	Key           string            `json:"key,omitempty"`           // and its key
+
+	// the secret itself while a feed is being added, it only goes to
+	// secrets.json once the feed was added
+	Pending *string `json:"-"`
+}
+
+// ScrapeRule turns a web page without a feed into one, with css selectors.
+// Item picks every item, the rest are looked for inside each item. A selector
+// can end with @attr to take an attribute instead of the text (time@datetime)
+type ScrapeRule struct {
+	Item    string `json:"item"`
+	Title   string `json:"title,omitempty"`   // the first heading or link when empty
+	Link    string `json:"link,omitempty"`    // the first link when empty, its href is used
+	Date    string `json:"date,omitempty"`    // no dates when empty
+	Content string `json:"content,omitempty"` // the whole item when empty
+}
+
+// AtomLink is an <atom:link>, rel="self" points to where the feed lives
+type AtomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+}
+
+// FeedMove is a new url for a feed, Permanent is for 301/308 redirects, the
+// rest (a different self link) are only hints
+type FeedMove struct {
+	URL       string
+	Permanent bool
+}
+
+// FeedStatus is how the last fetches of a feed went
+type FeedStatus struct {
+	LastFetch     time.Time `json:"lastFetch,omitempty"`
+	LastSuccess   time.Time `json:"lastSuccess,omitempty"`
+	LastStatus    int       `json:"lastStatus,omitempty"` // http status, 0 when the server couldn't be reached
+	LastError     string    `json:"lastError,omitempty"`
+	Failures      int       `json:"failures,omitempty"` // in a row, reset by a successful fetch
+	AvgResponseMs int64     `json:"avgResponseMs,omitempty"`
+	LastNewItem   time.Time `json:"lastNewItem,omitempty"`
+	MovedTo       string    `json:"movedTo,omitempty"`  // a new url waiting for the user to accept it
+	NotFound      int       `json:"notFound,omitempty"` // 404s in a row
+	Dead          bool      `json:"dead,omitempty"`     // gone (410) or not found too many times, not refreshed in the background
+}
+
+// Retention says how many cached items we keep, zero means no limit.
+// Starred items are never purged
+type Retention struct {
+	MaxItems   int  `json:"maxItems"`   // per feed
+	MaxAgeDays int  `json:"maxAgeDays"` // items older than this are dropped
+	KeepUnread bool `json:"keepUnread"` // unread items are kept no matter what
 }
 
 type FeedFolder struct {
@@ -35,3 +142,15 @@ type FeedData struct {
 type FolderData struct {
 	Folders []FeedFolder `json:"folders"`
 }
+
+// CachedFeed holds the items we've already seen for a feed, so they can be
+// searched (and read) without hitting the network again
+type CachedFeed struct {
+	Title     string    `json:"title"`
+	Items     []Item    `json:"items"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+type ItemCache struct {
+	Feeds map[string]*CachedFeed `json:"feeds"` // keyed by feed url
+}
@@ -0,0 +1,134 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Send shows a desktop notification through the freedesktop D-Bus
+// interface (using gdbus), or notify-send. It fails when neither is
+// available or works, the terminal can get the notification instead (see
+// TerminalSequence)
+func Send(title, body string) error {
+	if _, err := exec.LookPath("gdbus"); err == nil {
+		err := exec.Command("gdbus", "call", "--session",
+			"--dest", "org.freedesktop.Notifications",
+			"--object-path", "/org/freedesktop/Notifications",
+			"--method", "org.freedesktop.Notifications.Notify",
+			"core-rss", "0", "''", gvariantString(title), gvariantString(body), "[]", "{}", "5000").Run()
+		if err == nil {
+			return nil
+		}
+	}
+
+	if _, err := exec.LookPath("notify-send"); err == nil {
+		err := exec.Command("notify-send", "--app-name=core-rss", title, body).Run()
+		if err == nil {
+			return nil
+		}
+		return err
+	}
+
+	return errors.New("no desktop notifications (gdbus or notify-send)")
+}
+
+func gvariantString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `'`, `\'`)
+	return "'" + s + "'"
+}
+
+// TerminalSequence rings the bell and sends OSC 9, which some terminals
+// (iTerm2, kitty, Windows Terminal...) turn into a desktop notification. It
+// has to be written between two draws of the screen
+func TerminalSequence(title, body string) string {
+	message := strings.NewReplacer("\x07", "", "\x1b", "").Replace(title + ": " + body)
+	return fmt.Sprintf("\a\x1b]9;%s\x07", message)
+}
+
+// Aggregator collects new items and sends at most one notification every
+// interval, summing them up per group (folder) when there's more than one
+type Aggregator struct {
+	interval time.Duration
+	send     func(title, body string) error
+
+	mu      sync.Mutex
+	pending map[string][]string // group -> item titles
+	last    time.Time
+	timer   *time.Timer
+}
+
+func NewAggregator(interval time.Duration, send func(title, body string) error) *Aggregator {
+	return &Aggregator{interval: interval, send: send, pending: map[string][]string{}}
+}
+
+// Add queues a notification for a new item, it's sent right away unless one
+// was sent less than an interval ago
+func (a *Aggregator) Add(group, itemTitle string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.pending[group] = append(a.pending[group], itemTitle)
+	if a.timer != nil {
+		return
+	}
+
+	wait := a.interval - time.Since(a.last)
+	if wait < 0 {
+		// a little delay still, so a refresh of many feeds ends up in one notification
+		wait = 2 * time.Second
+	}
+	a.timer = time.AfterFunc(wait, a.flush)
+}
+
+func (a *Aggregator) flush() {
+	a.mu.Lock()
+	pending := a.pending
+	a.pending = map[string][]string{}
+	a.timer = nil
+	a.last = time.Now()
+	a.mu.Unlock()
+
+	if title, body, ok := summarize(pending); ok {
+		a.send(title, body)
+	}
+}
+
+func summarize(pending map[string][]string) (string, string, bool) {
+	total := 0
+	groups := make([]string, 0, len(pending))
+	for group, titles := range pending {
+		total += len(titles)
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+
+	switch {
+	case total == 0:
+		return "", "", false
+	case total == 1:
+		group := groups[0]
+		return "New item in " + group, pending[group][0], true
+	case len(groups) == 1:
+		group := groups[0]
+		return fmt.Sprintf("%d new items in %s", total, group), strings.Join(firstTitles(pending[group], 5), "\n"), true
+	}
+
+	parts := make([]string, 0, len(groups))
+	for _, group := range groups {
+		parts = append(parts, fmt.Sprintf("%s: %d", group, len(pending[group])))
+	}
+	return fmt.Sprintf("%d new items", total), strings.Join(parts, ", "), true
+}
+
+func firstTitles(titles []string, n int) []string {
+	if len(titles) <= n {
+		return titles
+	}
+	return append(titles[:n:n], fmt.Sprintf("and %d more", len(titles)-n))
+}
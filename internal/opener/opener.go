@@ -0,0 +1,145 @@
+package opener
+
+import (
+	"fmt"
+	"github.com/rzinak/core-rss/internal/config"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+)
+
+type rule struct {
+	pattern *regexp.Regexp
+	command []string
+}
+
+// Opener decides which program opens a url. GOOS, Getenv and Exec are
+// fields so they can be swapped, they default to the real ones
+type Opener struct {
+	rules  []rule
+	GOOS   string
+	Getenv func(key string) string
+	Exec   func(name string, args ...string) error
+}
+
+// New compiles the user rules, the first rule whose pattern matches the url
+// wins. Commands are split on spaces and {url} is replaced by the url, if
+// there's no {url} it's appended at the end
+func New(rules []config.OpenerRule) (*Opener, error) {
+	o := &Opener{
+		GOOS:   runtime.GOOS,
+		Getenv: os.Getenv,
+		Exec:   start,
+	}
+
+	for i, r := range rules {
+		pattern, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return o, fmt.Errorf("opener %d: %v", i+1, err)
+		}
+		command := strings.Fields(r.Command)
+		if len(command) == 0 {
+			return o, fmt.Errorf("opener %d: empty command", i+1)
+		}
+		o.rules = append(o.rules, rule{pattern: pattern, command: command})
+	}
+	return o, nil
+}
+
+// start runs the command without waiting for it, its output is discarded so
+// it doesn't mess with the tui
+func start(name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
+
+func expand(command []string, target string) (string, []string) {
+	args := make([]string, 0, len(command))
+	replaced := false
+	for _, arg := range command[1:] {
+		if strings.Contains(arg, "{url}") {
+			arg = strings.ReplaceAll(arg, "{url}", target)
+			replaced = true
+		}
+		args = append(args, arg)
+	}
+	if !replaced {
+		args = append(args, target)
+	}
+	return command[0], args
+}
+
+// Command returns the program (and its arguments) that would open the url
+func (o *Opener) Command(target string) (string, []string) {
+	for _, r := range o.rules {
+		if r.pattern.MatchString(target) {
+			return expand(r.command, target)
+		}
+	}
+
+	// $BROWSER can be a list of browsers separated by ':', with %s for the url
+	if browser := o.Getenv("BROWSER"); browser != "" {
+		first := strings.Split(browser, ":")[0]
+		if command := strings.Fields(strings.ReplaceAll(first, "%s", "{url}")); len(command) > 0 {
+			return expand(command, target)
+		}
+	}
+
+	switch o.GOOS {
+	case "darwin":
+		return "open", []string{target}
+	case "windows":
+		return "rundll32", []string{"url.dll,FileProtocolHandler", target}
+	default:
+		return "xdg-open", []string{target}
+	}
+}
+
+// Open opens the url with whatever program Command picks, urls without a
+// scheme are taken as http. Local files (downloaded episodes) are opened as
+// they are
+func (o *Opener) Open(rawUrl string) error {
+	if isLocal(rawUrl) {
+		name, args := o.Command(rawUrl)
+		return o.Exec(name, args...)
+	}
+
+	parsedURL, err := url.Parse(rawUrl)
+	if err != nil {
+		return err
+	}
+
+	if parsedURL.Scheme == "" {
+		parsedURL.Scheme = "http"
+	}
+
+	name, args := o.Command(parsedURL.String())
+	return o.Exec(name, args...)
+}
+
+func isLocal(target string) bool {
+	if filepath.IsAbs(target) {
+		return true
+	}
+	_, err := os.Stat(target)
+	return err == nil
+}
+
+// OpenWith runs command for the target instead of picking one, it's for
+// commands the user configured elsewhere (like the podcast player)
+func (o *Opener) OpenWith(command string, target string) error {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return o.Open(target)
+	}
+	name, args := expand(fields, target)
+	return o.Exec(name, args...)
+}
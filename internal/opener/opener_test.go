@@ -0,0 +1,117 @@
+package opener
+
+import (
+	"github.com/rzinak/core-rss/internal/config"
+	"reflect"
+	"testing"
+)
+
+// fake returns an opener for goos and $BROWSER that records what it runs
+// instead of running it
+func fake(t *testing.T, rules []config.OpenerRule, goos, browser string) (*Opener, *[]string) {
+	t.Helper()
+	o, err := New(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ran []string
+	o.GOOS = goos
+	o.Getenv = func(key string) string {
+		if key == "BROWSER" {
+			return browser
+		}
+		return ""
+	}
+	o.Exec = func(name string, args ...string) error {
+		ran = append([]string{name}, args...)
+		return nil
+	}
+	return o, &ran
+}
+
+func TestOpen(t *testing.T) {
+	rules := []config.OpenerRule{
+		{Pattern: `youtube\.com|\.mp4$`, Command: "mpv --title={url} {url}"},
+		{Pattern: `\.pdf$`, Command: "zathura"},
+		{Pattern: `.*`, Command: "firefox"},
+	}
+
+	tests := []struct {
+		name    string
+		rules   []config.OpenerRule
+		goos    string
+		browser string
+		url     string
+		want    []string
+	}{
+		{"darwin", nil, "darwin", "", "https://example.com", []string{"open", "https://example.com"}},
+		{"windows", nil, "windows", "", "https://example.com", []string{"rundll32", "url.dll,FileProtocolHandler", "https://example.com"}},
+		{"xdg-open", nil, "linux", "", "https://example.com", []string{"xdg-open", "https://example.com"}},
+		{"other unix", nil, "freebsd", "", "https://example.com", []string{"xdg-open", "https://example.com"}},
+		{"no scheme", nil, "linux", "", "example.com/post", []string{"xdg-open", "http://example.com/post"}},
+		{"local file", nil, "linux", "", "/home/me/Podcasts/episode.mp3", []string{"xdg-open", "/home/me/Podcasts/episode.mp3"}},
+		{"browser", nil, "darwin", "firefox", "https://example.com", []string{"firefox", "https://example.com"}},
+		{"browser with %s", nil, "linux", "firefox --new-tab %s", "https://example.com", []string{"firefox", "--new-tab", "https://example.com"}},
+		{"browser list", nil, "linux", "lynx:firefox", "https://example.com", []string{"lynx", "https://example.com"}},
+		{"browser list with %s", nil, "linux", "w3m -o %s:firefox", "https://example.com", []string{"w3m", "-o", "https://example.com"}},
+		{"first rule wins", rules, "linux", "lynx", "https://www.youtube.com/watch?v=1", []string{"mpv", "--title=https://www.youtube.com/watch?v=1", "https://www.youtube.com/watch?v=1"}},
+		{"url appended", rules, "linux", "", "https://example.com/a.pdf", []string{"zathura", "https://example.com/a.pdf"}},
+		{"catch all rule", rules, "windows", "lynx", "https://example.com", []string{"firefox", "https://example.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, ran := fake(t, tt.rules, tt.goos, tt.browser)
+			if err := o.Open(tt.url); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*ran, tt.want) {
+				t.Errorf("ran %q, want %q", *ran, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenWith(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		target  string
+		want    []string
+	}{
+		{"url appended", "mpv --no-video", "https://example.com/a.mp3", []string{"mpv", "--no-video", "https://example.com/a.mp3"}},
+		{"url expanded", "vlc --input={url}", "https://example.com/a.mp3", []string{"vlc", "--input=https://example.com/a.mp3"}},
+		{"no command", "", "https://example.com/a.mp3", []string{"xdg-open", "https://example.com/a.mp3"}},
+		{"no command, local file", "", "/home/me/Podcasts/a.mp3", []string{"xdg-open", "/home/me/Podcasts/a.mp3"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o, ran := fake(t, nil, "linux", "")
+			if err := o.OpenWith(tt.command, tt.target); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*ran, tt.want) {
+				t.Errorf("ran %q, want %q", *ran, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []config.OpenerRule
+	}{
+		{"bad pattern", []config.OpenerRule{{Pattern: "(", Command: "mpv"}}},
+		{"empty command", []config.OpenerRule{{Pattern: ".*", Command: "  "}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.rules); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"github.com/rzinak/core-rss/internal/models"
+	"golang.org/x/net/html"
+	"strings"
+)
+
+// atom feeds are decoded into these and turned into the same models.Feed an
+// rss feed gives
+
+type atomLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Text  string `xml:",chardata"`
+	Inner string `xml:",innerxml"`
+}
+
+// html returns the text as html, whatever its type
+func (t atomText) html() string {
+	switch t.Type {
+	case "xhtml":
+		return strings.TrimSpace(t.Inner)
+	case "html":
+		return strings.TrimSpace(t.Text)
+	}
+	return html.EscapeString(strings.TrimSpace(t.Text))
+}
+
+// text returns the text without markup, for titles. An xhtml one has no text
+// of its own, it's all inside a <div>
+func (t atomText) text() string {
+	if t.Type != "html" && t.Type != "xhtml" {
+		return strings.TrimSpace(t.Text)
+	}
+	doc, err := html.Parse(strings.NewReader(t.html()))
+	if err != nil {
+		return strings.TrimSpace(t.Text)
+	}
+	return strings.Join(strings.Fields(nodeText(doc)), " ")
+}
+
+type atomEntry struct {
+	Title     atomText   `xml:"title"`
+	ID        string     `xml:"id"`
+	Links     []atomLink `xml:"link"`
+	Published string     `xml:"published"`
+	Updated   string     `xml:"updated"`
+	Summary   atomText   `xml:"summary"`
+	Content   atomText   `xml:"content"`
+	Authors   []struct {
+		Name string `xml:"name"`
+	} `xml:"author"`
+	Categories []struct {
+		Term string `xml:"term,attr"`
+	} `xml:"category"`
+}
+
+type atomFeed struct {
+	Title   atomText    `xml:"title"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+func (a *atomFeed) toFeed() *models.Feed {
+	feed := &models.Feed{Title: a.Title.text()}
+	for _, link := range a.Links {
+		feed.Links = append(feed.Links, models.AtomLink{Href: link.Href, Rel: link.Rel})
+	}
+
+	for _, entry := range a.Entries {
+		item := models.Item{
+			Title:       entry.Title.text(),
+			GUID:        strings.TrimSpace(entry.ID),
+			PubDate:     strings.TrimSpace(entry.Published),
+			Description: entry.Summary.html(),
+			Content:     entry.Content.html(),
+		}
+		if item.PubDate == "" {
+			item.PubDate = strings.TrimSpace(entry.Updated)
+		}
+		if len(entry.Authors) > 0 {
+			item.Author = strings.TrimSpace(entry.Authors[0].Name)
+		}
+		for _, category := range entry.Categories {
+			item.Categories = append(item.Categories, category.Term)
+		}
+
+		for _, link := range entry.Links {
+			switch link.Rel {
+			case "", "alternate":
+				if item.Link == "" {
+					item.Link = link.Href
+				}
+			case "enclosure":
+				item.Enclosures = append(item.Enclosures, models.Enclosure{URL: link.Href, Type: link.Type, Length: link.Length})
+			}
+		}
+		feed.Items = append(feed.Items, item)
+	}
+	return feed
+}
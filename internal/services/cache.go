@@ -0,0 +1,175 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/rzinak/core-rss/internal/config"
+	"github.com/rzinak/core-rss/internal/models"
+	"github.com/rzinak/core-rss/pkg/utils"
+	"os"
+	"sort"
+	"time"
+)
+
+// LoadCache reads the cached items from disk, an empty cache is returned if
+// nothing was cached yet
+func LoadCache() (*models.ItemCache, error) {
+	cache := &models.ItemCache{Feeds: map[string]*models.CachedFeed{}}
+
+	filePath, err := config.Path("cache.json")
+	if err != nil {
+		return cache, err
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cache, nil
+		}
+		return cache, err
+	}
+	defer file.Close()
+
+	if err := json.NewDecoder(file).Decode(cache); err != nil {
+		return cache, err
+	}
+	if cache.Feeds == nil {
+		cache.Feeds = map[string]*models.CachedFeed{}
+	}
+	return cache, nil
+}
+
+func SaveCache(cache *models.ItemCache) error {
+	filePath, err := config.Path("cache.json")
+	if err != nil {
+		return err
+	}
+
+	// write to a temp file first so a crash doesn't leave us with half a cache
+	tmpPath := filePath + ".tmp"
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+
+	if err := json.NewEncoder(file).Encode(cache); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, filePath)
+}
+
+// ItemKey identifies an item inside its feed, not every feed has guids so we
+// fallback to the link and then the title
+func ItemKey(item models.Item) string {
+	if item.GUID != "" {
+		return item.GUID
+	}
+	if item.Link != "" {
+		return item.Link
+	}
+	return item.Title
+}
+
+// CacheItems merges freshly fetched items into the cache and returns only the
+// ones we didn't have before. The state we keep for known items (read,
+// starred) is copied back into items so they can be displayed as they are
+func CacheItems(cache *models.ItemCache, feed *models.Feed, items []models.Item) []models.Item {
+	cached, ok := cache.Feeds[feed.URL]
+	if !ok {
+		cached = &models.CachedFeed{}
+		cache.Feeds[feed.URL] = cached
+	}
+	cached.Title = feed.Title
+	cached.UpdatedAt = time.Now()
+
+	known := make(map[string]int, len(cached.Items))
+	for i, item := range cached.Items {
+		known[ItemKey(item)] = i
+	}
+
+	var newItems []models.Item
+	for j := range items {
+		items[j].FeedURL = feed.URL
+		items[j].FeedTitle = feed.Title
+		if i, ok := known[ItemKey(items[j])]; ok {
+			// the publisher may have edited it, keep the latest version
+			items[j].Read = cached.Items[i].Read
+			items[j].Starred = cached.Items[i].Starred
+			items[j].FullContent = cached.Items[i].FullContent
+			cached.Items[i] = items[j]
+			continue
+		}
+		newItems = append(newItems, items[j])
+	}
+
+	// new items go on top, that's the order feeds usually come in
+	cached.Items = append(newItems, cached.Items...)
+	return newItems
+}
+
+// CachedItems returns every cached item of a feed
+func CachedItems(cache *models.ItemCache, feedUrl string) []models.Item {
+	if cached, ok := cache.Feeds[feedUrl]; ok {
+		return cached.Items
+	}
+	return nil
+}
+
+func findCached(cache *models.ItemCache, item models.Item) *models.Item {
+	cached, ok := cache.Feeds[item.FeedURL]
+	if !ok {
+		return nil
+	}
+	key := ItemKey(item)
+	for i := range cached.Items {
+		if ItemKey(cached.Items[i]) == key {
+			return &cached.Items[i]
+		}
+	}
+	return nil
+}
+
+// MarkRead flags the cached copy of the item as read, it returns false when
+// the item isn't cached (or was already read) so callers know if it's worth saving
+func MarkRead(cache *models.ItemCache, item models.Item) bool {
+	cachedItem := findCached(cache, item)
+	if cachedItem == nil || cachedItem.Read {
+		return false
+	}
+	cachedItem.Read = true
+	return true
+}
+
+// ToggleStar stars or unstars the cached copy of the item and returns the
+// new state. Starred items are kept in the cache for good, even after they
+// are gone from the feed
+func ToggleStar(cache *models.ItemCache, item models.Item) (bool, error) {
+	cachedItem := findCached(cache, item)
+	if cachedItem == nil {
+		return false, fmt.Errorf("item %s is not cached", item.Title)
+	}
+	cachedItem.Starred = !cachedItem.Starred
+	return cachedItem.Starred, nil
+}
+
+// StarredItems returns the starred items of every cached feed, even the
+// ones that were removed since, newest first
+func StarredItems(cache *models.ItemCache) []models.Item {
+	var items []models.Item
+	for _, cached := range cache.Feeds {
+		for _, item := range cached.Items {
+			if item.Starred {
+				items = append(items, item)
+			}
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return utils.ParseDate(items[i].PubDate).After(utils.ParseDate(items[j].PubDate))
+	})
+	return items
+}
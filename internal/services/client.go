@@ -0,0 +1,118 @@
+package services
+
+import (
+	"crypto/tls"
+	"fmt"
+	"github.com/rzinak/core-rss/internal/config"
+	"github.com/rzinak/core-rss/internal/models"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// every feed is fetched through the same client, so connections are reused.
+// Feeds with a client certificate get their own, kept by certificate
+var (
+	httpClient = &http.Client{Transport: newTransport(nil)}
+
+	certClientsMu sync.Mutex
+	certClients   = map[string]*http.Client{}
+)
+
+func newTransport(certificates []tls.Certificate) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = 30 * time.Second
+	if certificates != nil {
+		transport.TLSClientConfig = &tls.Config{Certificates: certificates}
+	}
+	return transport
+}
+
+// clientFor returns the client to fetch a feed with
+func clientFor(auth *models.FeedAuth) (*http.Client, error) {
+	if auth == nil || auth.Cert == "" {
+		return httpClient, nil
+	}
+
+	certClientsMu.Lock()
+	defer certClientsMu.Unlock()
+
+	key := auth.Cert + "\x00" + auth.Key
+	if client, ok := certClients[key]; ok {
+		return client, nil
+	}
+
+	keyFile := auth.Key
+	if keyFile == "" {
+		// the key can be in the same pem file
+		keyFile = auth.Cert
+	}
+	certificate, err := tls.LoadX509KeyPair(auth.Cert, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("loading client certificate: %v", err)
+	}
+
+	client := &http.Client{Transport: newTransport([]tls.Certificate{certificate})}
+	certClients[key] = client
+	return client, nil
+}
+
+// stripAuth takes the credentials of the feed off a redirected request that
+// left the host they were meant for. net/http only drops Authorization and
+// Cookie (and keeps them for subdomains), not the headers of the feed
+func stripAuth(req *http.Request, via []*http.Request, auth *models.FeedAuth) {
+	if auth == nil || req.URL.Host == via[0].URL.Host {
+		return
+	}
+	for name := range auth.Headers {
+		req.Header.Del(name)
+	}
+	for name := range auth.SecretHeaders {
+		req.Header.Del(name)
+	}
+	req.Header.Del("Authorization")
+}
+
+// authorize adds the credentials of a feed to the request
+func authorize(req *http.Request, auth *models.FeedAuth) error {
+	if auth == nil {
+		return nil
+	}
+
+	for name, value := range auth.Headers {
+		req.Header.Set(name, value)
+	}
+	for name, ref := range auth.SecretHeaders {
+		value, err := config.ResolveSecret(ref)
+		if err != nil {
+			return fmt.Errorf("header %s: %v", name, err)
+		}
+		req.Header.Set(name, value)
+	}
+
+	authType := strings.ToLower(auth.Type)
+	if authType == "" {
+		return nil
+	}
+	if authType != "basic" && authType != "bearer" {
+		return fmt.Errorf("unknown auth type %q", auth.Type)
+	}
+
+	var secret string
+	if auth.Pending != nil {
+		secret = *auth.Pending
+	} else {
+		resolved, err := config.ResolveSecret(auth.Secret)
+		if err != nil {
+			return err
+		}
+		secret = resolved
+	}
+	if authType == "basic" {
+		req.SetBasicAuth(auth.Username, secret)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+secret)
+	}
+	return nil
+}
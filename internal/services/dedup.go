@@ -0,0 +1,126 @@
+package services
+
+import (
+	"github.com/rzinak/core-rss/internal/models"
+	"net/url"
+	"strings"
+	"unicode"
+)
+
+// CanonicalLink normalizes a link so the same article linked from different
+// feeds compares equal: tracking params (utm_*), fragments, trailing slashes
+// and the scheme are ignored
+func CanonicalLink(link string) string {
+	link = strings.TrimSpace(link)
+	if link == "" {
+		return ""
+	}
+
+	parsed, err := url.Parse(link)
+	if err != nil || parsed.Host == "" {
+		return strings.TrimRight(link, "/")
+	}
+
+	query := parsed.Query()
+	for param := range query {
+		if strings.HasPrefix(strings.ToLower(param), "utm_") {
+			query.Del(param)
+		}
+	}
+
+	canonical := strings.ToLower(strings.TrimPrefix(parsed.Host, "www.")) + strings.TrimRight(parsed.EscapedPath(), "/")
+	if encoded := query.Encode(); encoded != "" {
+		canonical += "?" + encoded
+	}
+	return canonical
+}
+
+// NormalizeTitle lowercases the title and keeps only letters and digits
+func NormalizeTitle(title string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// globalGUID reports whether a guid means the same thing in every feed, many
+// are only unique inside their feed ("42", "post-7")
+func globalGUID(guid string) bool {
+	if strings.HasPrefix(strings.ToLower(guid), "urn:") {
+		return true
+	}
+	parsed, err := url.Parse(guid)
+	return err == nil && parsed.Scheme != "" && parsed.Host != ""
+}
+
+func dedupKeys(item models.Item, byTitle bool) []string {
+	var keys []string
+	if item.GUID != "" {
+		if globalGUID(item.GUID) {
+			keys = append(keys, "guid:"+item.GUID)
+		} else {
+			keys = append(keys, "guid:"+item.FeedURL+"\x00"+item.GUID)
+		}
+	}
+	if link := CanonicalLink(item.Link); link != "" {
+		keys = append(keys, "link:"+link)
+	}
+	if byTitle {
+		if title := NormalizeTitle(item.Title); title != "" {
+			keys = append(keys, "title:"+title)
+		}
+	}
+	return keys
+}
+
+// Dedup collapses the items that are the same article (same guid when it's a
+// url or a urn, same canonical link or, if byTitle is set, same normalized
+// title). The first one is kept, the others end up in its Duplicates
+func Dedup(items []models.Item, byTitle bool) []models.Item {
+	seen := map[string]int{}
+	var deduped []models.Item
+
+	for _, item := range items {
+		keys := dedupKeys(item, byTitle)
+
+		original := -1
+		for _, key := range keys {
+			if i, ok := seen[key]; ok {
+				original = i
+				break
+			}
+		}
+
+		if original >= 0 {
+			deduped[original].Duplicates = append(deduped[original].Duplicates, item)
+			for _, key := range keys {
+				seen[key] = original
+			}
+			continue
+		}
+
+		deduped = append(deduped, item)
+		for _, key := range keys {
+			seen[key] = len(deduped) - 1
+		}
+	}
+	return deduped
+}
+
+// OtherFeeds returns the title of every other feed the item appeared in,
+// duplicates from the item's own feed don't count
+func OtherFeeds(item models.Item) []string {
+	var feeds []string
+	seen := map[string]bool{item.FeedURL: true}
+	for _, duplicate := range item.Duplicates {
+		if seen[duplicate.FeedURL] {
+			continue
+		}
+		seen[duplicate.FeedURL] = true
+		feeds = append(feeds, duplicate.FeedTitle)
+	}
+	return feeds
+}
@@ -0,0 +1,256 @@
+package services
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"github.com/rzinak/core-rss/internal/models"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+type DownloadState int
+
+const (
+	DownloadQueued DownloadState = iota
+	DownloadRunning
+	DownloadDone
+	DownloadFailed
+)
+
+// Download is an enclosure being saved to disk, Done and Total are in bytes
+// (Total is 0 when the server doesn't tell us)
+type Download struct {
+	URL   string
+	Path  string
+	Total int64
+	Done  int64
+	State DownloadState
+	Err   error
+
+	auth *models.FeedAuth
+}
+
+// Percent is how much of the download is done, -1 if the size is unknown
+func (d *Download) Percent() int {
+	if d.Total <= 0 {
+		return -1
+	}
+	return int(d.Done * 100 / d.Total)
+}
+
+// DownloadManager saves enclosures to a directory, one at a time. Partial
+// downloads are kept as .part files and resumed with a Range request
+type DownloadManager struct {
+	dir        string
+	onProgress func(d Download, queued int)
+
+	mu      sync.Mutex
+	queue   []*Download
+	running bool
+}
+
+// NewDownloadManager creates the manager, onProgress is called (from the
+// download goroutine, with a copy of the download) whenever a download moves
+// forward, finishes or fails
+func NewDownloadManager(dir string, onProgress func(d Download, queued int)) *DownloadManager {
+	return &DownloadManager{dir: dir, onProgress: onProgress}
+}
+
+// FileName picks the name an enclosure is saved as, from the last part of
+// its url and a short hash of the whole url, since plenty of hosts name every
+// episode audio.mp3
+func FileName(rawUrl string) string {
+	sum := sha1.Sum([]byte(rawUrl))
+	hash := hex.EncodeToString(sum[:4])
+
+	name := ""
+	if parsed, err := url.Parse(rawUrl); err == nil {
+		name = path.Base(parsed.Path)
+	}
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "" || name == "." || name == "/" || name == "_" {
+		return "download-" + hash
+	}
+	ext := filepath.Ext(name)
+	return strings.TrimSuffix(name, ext) + "-" + hash + ext
+}
+
+// PathFor is where the enclosure is (or will be) saved
+func (m *DownloadManager) PathFor(rawUrl string) string {
+	return filepath.Join(m.dir, FileName(rawUrl))
+}
+
+// Downloaded reports whether the enclosure was already fully downloaded
+func (m *DownloadManager) Downloaded(rawUrl string) bool {
+	_, err := os.Stat(m.PathFor(rawUrl))
+	return err == nil
+}
+
+// Enqueue adds the enclosure to the queue, it's downloaded once the ones
+// before it are done. auth is the one of the item's feed (nil if it has none),
+// premium podcasts want it for their episodes too
+func (m *DownloadManager) Enqueue(rawUrl string, auth *models.FeedAuth) error {
+	if m.Downloaded(rawUrl) {
+		return fmt.Errorf("%s was already downloaded", FileName(rawUrl))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, queued := range m.queue {
+		if queued.URL == rawUrl {
+			return fmt.Errorf("%s is already in the queue", FileName(rawUrl))
+		}
+	}
+
+	download := &Download{URL: rawUrl, Path: m.PathFor(rawUrl), auth: auth}
+	m.queue = append(m.queue, download)
+	if !m.running {
+		m.running = true
+		go m.work()
+	}
+	return nil
+}
+
+func (m *DownloadManager) work() {
+	for {
+		m.mu.Lock()
+		if len(m.queue) == 0 {
+			m.running = false
+			m.mu.Unlock()
+			return
+		}
+		download := m.queue[0]
+		m.mu.Unlock()
+
+		download.State = DownloadRunning
+		if err := m.fetch(download); err != nil {
+			download.State = DownloadFailed
+			download.Err = err
+		} else {
+			download.State = DownloadDone
+		}
+
+		m.mu.Lock()
+		m.queue = m.queue[1:]
+		queued := len(m.queue)
+		m.mu.Unlock()
+		m.onProgress(*download, queued)
+	}
+}
+
+func (m *DownloadManager) queued() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.queue) - 1
+}
+
+func (m *DownloadManager) fetch(download *Download) error {
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		return err
+	}
+
+	partPath := download.Path + ".part"
+	var offset int64
+	if info, err := os.Stat(partPath); err == nil {
+		offset = info.Size()
+	}
+
+	req, err := http.NewRequest(http.MethodGet, download.URL, nil)
+	if err != nil {
+		return err
+	}
+	if err := authorize(req, download.auth); err != nil {
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	shared, err := clientFor(download.auth)
+	if err != nil {
+		return err
+	}
+	client := *shared
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return fmt.Errorf("stopped after 10 redirects")
+		}
+		stripAuth(req, via, download.auth)
+		return nil
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		flags |= os.O_APPEND
+	case http.StatusOK:
+		// the server ignored the range, start over
+		offset = 0
+		flags |= os.O_TRUNC
+	case http.StatusRequestedRangeNotSatisfiable:
+		// the .part file is already complete
+		return os.Rename(partPath, download.Path)
+	default:
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+
+	file, err := os.OpenFile(partPath, flags, 0644)
+	if err != nil {
+		return err
+	}
+
+	download.Done = offset
+	if resp.ContentLength > 0 {
+		download.Total = offset + resp.ContentLength
+	}
+
+	buf := make([]byte, 64*1024)
+	lastReport := time.Time{}
+	for {
+		n, readErr := resp.Body.Read(buf)
+		if n > 0 {
+			if _, err := file.Write(buf[:n]); err != nil {
+				file.Close()
+				return err
+			}
+			download.Done += int64(n)
+			// no need to redraw the status bar for every chunk
+			if time.Since(lastReport) > 500*time.Millisecond {
+				lastReport = time.Now()
+				m.onProgress(*download, m.queued())
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			file.Close()
+			return readErr
+		}
+	}
+
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(partPath, download.Path)
+}
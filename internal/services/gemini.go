@@ -0,0 +1,277 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"github.com/rzinak/core-rss/internal/models"
+	"html"
+	"io"
+	"net"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// gemini and gopher documents can't be bigger than this
+const maxDocumentSize = 16 << 20
+
+// geminiSource fetches gemini:// feeds: atom served over gemini, or gemtext
+// subscription pages (https://geminiprotocol.net/docs/companion/subscription.gmi)
+type geminiSource struct{}
+
+// geminiGet requests a gemini url, following up to 5 redirects. It returns
+// the mime type of the answer and, like getFollowing, where the permanent
+// redirects (31) end
+func geminiGet(ctx context.Context, rawUrl string, auth *models.FeedAuth) ([]byte, string, string, error) {
+	permanentUrl := ""
+	temporary := false
+
+	for redirects := 0; ; redirects++ {
+		status, meta, body, err := geminiRequest(ctx, rawUrl, auth)
+		if err != nil {
+			return nil, "", "", err
+		}
+
+		switch status / 10 {
+		case 2:
+			return body, meta, permanentUrl, nil
+		case 3:
+			if redirects >= 5 {
+				return nil, "", "", fmt.Errorf("stopped after 5 redirects")
+			}
+			base, _ := url.Parse(rawUrl)
+			next, err := base.Parse(meta)
+			if err != nil {
+				return nil, "", "", fmt.Errorf("bad redirect to %q: %v", meta, err)
+			}
+			if next.Scheme != "gemini" {
+				// the next request would speak gemini to it on port 1965
+				return nil, "", "", fmt.Errorf("%s redirects to %s, outside of gemini", rawUrl, next)
+			}
+			logToFile(fmt.Sprintf("redirect %d: %s -> %s", status, rawUrl, next))
+			if status != 31 {
+				temporary = true
+			}
+			rawUrl = next.String()
+			if !temporary {
+				permanentUrl = rawUrl
+			}
+		case 1:
+			return nil, "", "", fmt.Errorf("%s asks for input, it can't be a feed", rawUrl)
+		case 6:
+			return nil, "", "", fmt.Errorf("%s needs a client certificate (set cert and key in the feed auth): %s", rawUrl, meta)
+		default:
+			return nil, "", "", &StatusError{URL: rawUrl, Code: status, Status: fmt.Sprintf("%d %s", status, meta)}
+		}
+	}
+}
+
+func geminiRequest(ctx context.Context, rawUrl string, auth *models.FeedAuth) (int, string, []byte, error) {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil {
+		return 0, "", nil, err
+	}
+	host := parsed.Host
+	if parsed.Port() == "" {
+		host = net.JoinHostPort(parsed.Hostname(), "1965")
+	}
+
+	// self signed certificates are the norm, the chain isn't verified but the
+	// certificate is pinned on first use (see checkHostCertificate). It runs
+	// before a client certificate is sent
+	config := &tls.Config{
+		InsecureSkipVerify: true,
+		ServerName:         parsed.Hostname(),
+		MinVersion:         tls.VersionTLS12,
+		VerifyConnection: func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) == 0 {
+				return fmt.Errorf("%s sent no certificate", host)
+			}
+			return checkHostCertificate(host, state.PeerCertificates[0])
+		},
+	}
+	if auth != nil && auth.Cert != "" {
+		keyFile := auth.Key
+		if keyFile == "" {
+			keyFile = auth.Cert
+		}
+		certificate, err := tls.LoadX509KeyPair(auth.Cert, keyFile)
+		if err != nil {
+			return 0, "", nil, fmt.Errorf("loading client certificate: %v", err)
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+
+	dialer := &tls.Dialer{NetDialer: &net.Dialer{Timeout: 30 * time.Second}, Config: config}
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return 0, "", nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Minute))
+
+	if _, err := fmt.Fprintf(conn, "%s\r\n", parsed.String()); err != nil {
+		return 0, "", nil, err
+	}
+
+	reader := bufio.NewReader(io.LimitReader(conn, maxDocumentSize))
+	header, err := reader.ReadString('\n')
+	if err != nil {
+		return 0, "", nil, fmt.Errorf("reading the gemini header: %v", err)
+	}
+	var status int
+	var meta string
+	header = strings.TrimRight(header, "\r\n")
+	if _, err := fmt.Sscanf(header, "%d", &status); err != nil || status < 10 || status > 69 {
+		return 0, "", nil, fmt.Errorf("bad gemini header %q", header)
+	}
+	if _, after, ok := strings.Cut(header, " "); ok {
+		meta = strings.TrimSpace(after)
+	}
+
+	if status/10 != 2 {
+		return status, meta, nil, nil
+	}
+	body, err := io.ReadAll(reader)
+	return status, meta, body, err
+}
+
+func (geminiSource) Open(ctx context.Context, feed *models.Feed) (*Document, error) {
+	body, mime, permanentUrl, err := geminiGet(ctx, feed.URL, feed.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasPrefix(mime, "text/gemini") {
+		// atom or rss over gemini
+		return &Document{Body: io.NopCloser(bytes.NewReader(body)), PermanentURL: permanentUrl}, nil
+	}
+
+	base := feed.URL
+	if permanentUrl != "" {
+		base = permanentUrl
+	}
+	return &Document{Feed: parseGemSubscription(string(body), base), PermanentURL: permanentUrl}, nil
+}
+
+// link lines of a subscription page start with a date: "=> url 2024-01-02 Title"
+var gemLinkLine = regexp.MustCompile(`^=>\s*(\S+)(?:\s+(.*))?$`)
+var gemDated = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})\s*[-:–]?\s*(.*)$`)
+
+// parseGemSubscription makes a feed of a gemtext page: the first heading is
+// the title and each dated link line is an item
+func parseGemSubscription(page, base string) *models.Feed {
+	feed := &models.Feed{URL: base}
+	baseUrl, _ := url.Parse(base)
+
+	for _, line := range strings.Split(page, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if feed.Title == "" && strings.HasPrefix(line, "# ") {
+			feed.Title = strings.TrimSpace(line[2:])
+			continue
+		}
+
+		match := gemLinkLine.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		dated := gemDated.FindStringSubmatch(strings.TrimSpace(match[2]))
+		if dated == nil {
+			continue
+		}
+
+		link := match[1]
+		if baseUrl != nil {
+			if resolved, err := baseUrl.Parse(link); err == nil {
+				link = resolved.String()
+			}
+		}
+		title := dated[2]
+		if title == "" {
+			title = link
+		}
+		feed.Items = append(feed.Items, models.Item{Title: title, Link: link, GUID: link, PubDate: dated[1]})
+	}
+
+	if feed.Title == "" {
+		feed.Title = base
+	}
+	return feed
+}
+
+// gemtextToHTML converts a gemtext document so the content view can show
+// it, relative links are resolved against base
+func gemtextToHTML(gemtext, base string) string {
+	baseUrl, _ := url.Parse(base)
+	var out strings.Builder
+	inPre, inList := false, false
+
+	for _, line := range strings.Split(gemtext, "\n") {
+		line = strings.TrimRight(line, "\r")
+
+		if strings.HasPrefix(line, "```") {
+			if inPre {
+				out.WriteString("</pre>\n")
+			} else {
+				out.WriteString("<pre>")
+			}
+			inPre = !inPre
+			continue
+		}
+		if inPre {
+			out.WriteString(html.EscapeString(line) + "\n")
+			continue
+		}
+
+		if strings.HasPrefix(line, "* ") {
+			if !inList {
+				out.WriteString("<ul>\n")
+				inList = true
+			}
+			out.WriteString("<li>" + html.EscapeString(strings.TrimSpace(line[2:])) + "</li>\n")
+			continue
+		}
+		if inList {
+			out.WriteString("</ul>\n")
+			inList = false
+		}
+
+		switch {
+		case strings.TrimSpace(line) == "":
+		case strings.HasPrefix(line, "###"):
+			out.WriteString("<h3>" + html.EscapeString(strings.TrimSpace(line[3:])) + "</h3>\n")
+		case strings.HasPrefix(line, "##"):
+			out.WriteString("<h2>" + html.EscapeString(strings.TrimSpace(line[2:])) + "</h2>\n")
+		case strings.HasPrefix(line, "#"):
+			out.WriteString("<h1>" + html.EscapeString(strings.TrimSpace(line[1:])) + "</h1>\n")
+		case strings.HasPrefix(line, ">"):
+			out.WriteString("<blockquote>" + html.EscapeString(strings.TrimSpace(line[1:])) + "</blockquote>\n")
+		case gemLinkLine.MatchString(line):
+			match := gemLinkLine.FindStringSubmatch(line)
+			link, text := match[1], strings.TrimSpace(match[2])
+			if baseUrl != nil {
+				if resolved, err := baseUrl.Parse(link); err == nil {
+					link = resolved.String()
+				}
+			}
+			if text == "" {
+				text = link
+			}
+			out.WriteString(fmt.Sprintf("<p><a href=\"%s\">%s</a></p>\n", html.EscapeString(link), html.EscapeString(text)))
+		default:
+			out.WriteString("<p>" + html.EscapeString(line) + "</p>\n")
+		}
+	}
+
+	if inPre {
+		out.WriteString("</pre>\n")
+	}
+	if inList {
+		out.WriteString("</ul>\n")
+	}
+	return out.String()
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/rzinak/core-rss/internal/models"
+	"html"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// gopherSource fetches gopher:// feeds: rss or atom files served over
+// gopher, or menus (phlogs) where every entry starting with a date is an item
+type gopherSource struct{}
+
+// gopherTarget splits a gopher url in its address, item type and selector
+func gopherTarget(rawUrl string) (string, byte, string, error) {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil {
+		return "", 0, "", err
+	}
+	host := parsed.Host
+	if parsed.Port() == "" {
+		host = net.JoinHostPort(parsed.Hostname(), "70")
+	}
+
+	// the path is /<type><selector>, an empty one is the root menu
+	itemType := byte('1')
+	selector := ""
+	if path := parsed.Path; len(path) > 1 {
+		itemType = path[1]
+		selector = path[2:]
+	}
+	return host, itemType, selector, nil
+}
+
+func gopherGet(ctx context.Context, rawUrl string) ([]byte, byte, error) {
+	host, itemType, selector, err := gopherTarget(rawUrl)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Minute))
+
+	if _, err := fmt.Fprintf(conn, "%s\r\n", selector); err != nil {
+		return nil, 0, err
+	}
+	body, err := io.ReadAll(io.LimitReader(conn, maxDocumentSize))
+	if err != nil {
+		return nil, 0, err
+	}
+	// text ends with a line with only a dot
+	body = bytes.TrimSuffix(bytes.TrimRight(body, "\r\n"), []byte("\n."))
+	return body, itemType, nil
+}
+
+func (gopherSource) Open(ctx context.Context, feed *models.Feed) (*Document, error) {
+	body, itemType, err := gopherGet(ctx, feed.URL)
+	if err != nil {
+		return nil, err
+	}
+	if itemType != '1' {
+		return &Document{Body: io.NopCloser(bytes.NewReader(body))}, nil
+	}
+	return &Document{Feed: parseGopherMenu(string(body), feed.URL)}, nil
+}
+
+// gopherEntry is a line of a gopher menu
+type gopherEntry struct {
+	itemType byte
+	text     string
+	url      string // empty for info lines
+}
+
+func parseGopherEntries(menu string) []gopherEntry {
+	var entries []gopherEntry
+	for _, line := range strings.Split(menu, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" || line == "." {
+			continue
+		}
+		fields := strings.Split(line[1:], "\t")
+		entry := gopherEntry{itemType: line[0], text: fields[0]}
+		if entry.itemType != 'i' && entry.itemType != '3' && len(fields) >= 3 {
+			selector, host, port := fields[1], fields[2], "70"
+			if len(fields) >= 4 && fields[3] != "" {
+				port = fields[3]
+			}
+			if entry.itemType == 'h' && strings.HasPrefix(selector, "URL:") {
+				// links to the web
+				entry.url = strings.TrimPrefix(selector, "URL:")
+			} else {
+				entry.url = fmt.Sprintf("gopher://%s/%c%s", net.JoinHostPort(host, port), entry.itemType, selector)
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// parseGopherMenu makes a feed of a phlog menu, its first info line is the
+// title and each link starting with a date is an item
+func parseGopherMenu(menu, base string) *models.Feed {
+	feed := &models.Feed{URL: base}
+	for _, entry := range parseGopherEntries(menu) {
+		if entry.url == "" {
+			if feed.Title == "" && strings.TrimSpace(entry.text) != "" {
+				feed.Title = strings.TrimSpace(entry.text)
+			}
+			continue
+		}
+
+		dated := gemDated.FindStringSubmatch(strings.TrimSpace(entry.text))
+		if dated == nil {
+			continue
+		}
+		title := dated[2]
+		if title == "" {
+			title = entry.url
+		}
+		feed.Items = append(feed.Items, models.Item{Title: title, Link: entry.url, GUID: entry.url, PubDate: dated[1]})
+	}
+
+	if feed.Title == "" {
+		feed.Title = base
+	}
+	return feed
+}
+
+// gopherToHTML converts a gopher document for the content view: text goes
+// in a <pre>, menus become lists of links
+func gopherToHTML(body []byte, itemType byte) string {
+	switch itemType {
+	case 'h':
+		return string(body)
+	case '1':
+		var out strings.Builder
+		for _, entry := range parseGopherEntries(string(body)) {
+			if entry.url == "" {
+				out.WriteString("<pre>" + html.EscapeString(entry.text) + "</pre>\n")
+				continue
+			}
+			out.WriteString(fmt.Sprintf("<p><a href=\"%s\">%s</a></p>\n", html.EscapeString(entry.url), html.EscapeString(entry.text)))
+		}
+		return out.String()
+	}
+	return "<pre>" + html.EscapeString(string(body)) + "</pre>"
+}
@@ -0,0 +1,164 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"github.com/rzinak/core-rss/internal/models"
+	"github.com/rzinak/core-rss/pkg/utils"
+	"net/http"
+	"time"
+)
+
+// StatusError is returned when the server answers a fetch with something
+// other than 200 OK
+type StatusError struct {
+	URL    string
+	Code   int
+	Status string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status fetching %s: %s", e.URL, e.Status)
+}
+
+// a feed answering 404 this many times in a row is considered dead
+const deadAfterNotFound = 5
+
+// RecordFetch keeps how the fetch of a feed went in its status. err is nil
+// when it worked, elapsed is how long the request took and newItems how many
+// items we hadn't seen before
+func RecordFetch(feed *models.Feed, err error, elapsed time.Duration, newItems int) {
+	if feed.Status == nil {
+		feed.Status = &models.FeedStatus{}
+	}
+	status := feed.Status
+	status.LastFetch = time.Now()
+
+	var statusErr *StatusError
+	switch {
+	case err == nil:
+		status.LastStatus = http.StatusOK
+	case errors.As(err, &statusErr):
+		status.LastStatus = statusErr.Code
+	default:
+		// no answer at all (dns, timeout...)
+		status.LastStatus = 0
+	}
+
+	// the average leans towards the latest fetches, servers change
+	if status.LastStatus != 0 {
+		ms := elapsed.Milliseconds()
+		if status.AvgResponseMs == 0 {
+			status.AvgResponseMs = ms
+		} else {
+			status.AvgResponseMs = (status.AvgResponseMs*4 + ms) / 5
+		}
+	}
+
+	if status.LastStatus == http.StatusNotFound {
+		status.NotFound++
+	} else {
+		status.NotFound = 0
+	}
+
+	if err != nil {
+		status.LastError = err.Error()
+		status.Failures++
+		if !status.Dead && (status.LastStatus == http.StatusGone || status.NotFound >= deadAfterNotFound) {
+			status.Dead = true
+			logToFile(fmt.Sprintf("feed %s is dead: %v", feed.URL, err))
+		}
+		return
+	}
+	status.LastSuccess = status.LastFetch
+	status.LastError = ""
+	status.Failures = 0
+	status.Dead = false
+	if newItems > 0 {
+		status.LastNewItem = status.LastFetch
+	}
+}
+
+// ItemsPerWeek is how often a feed publishes, from the dates of its cached
+// items. It's 0 when there's not enough to tell
+func ItemsPerWeek(items []models.Item) float64 {
+	var oldest, newest time.Time
+	dated := 0
+	for _, item := range items {
+		date := utils.ParseDate(item.PubDate)
+		if date.IsZero() {
+			continue
+		}
+		if oldest.IsZero() || date.Before(oldest) {
+			oldest = date
+		}
+		if newest.IsZero() || date.After(newest) {
+			newest = date
+		}
+		dated++
+	}
+
+	span := newest.Sub(oldest)
+	if dated < 2 || span <= 0 {
+		return 0
+	}
+	return float64(dated-1) / (span.Hours() / (24 * 7))
+}
+
+// ChangeFeedURL points the feed to a new url, its cached items move along.
+// When the new url has items cached already (another feed uses it) both are
+// merged, an item read or starred in either stays that way
+func ChangeFeedURL(cache *models.ItemCache, feed *models.Feed, newUrl string) {
+	oldUrl := feed.URL
+	feed.URL = newUrl
+	if oldUrl == newUrl {
+		return
+	}
+
+	cached, ok := cache.Feeds[oldUrl]
+	if !ok {
+		return
+	}
+	delete(cache.Feeds, oldUrl)
+	for i := range cached.Items {
+		cached.Items[i].FeedURL = newUrl
+	}
+
+	existing, ok := cache.Feeds[newUrl]
+	if !ok {
+		cache.Feeds[newUrl] = cached
+		return
+	}
+	known := make(map[string]int, len(existing.Items))
+	for i, item := range existing.Items {
+		known[ItemKey(item)] = i
+	}
+	for _, item := range cached.Items {
+		i, ok := known[ItemKey(item)]
+		if !ok {
+			existing.Items = append(existing.Items, item)
+			continue
+		}
+		kept := &existing.Items[i]
+		kept.Read = kept.Read || item.Read
+		kept.Starred = kept.Starred || item.Starred
+		if kept.FullContent == "" {
+			kept.FullContent = item.FullContent
+		}
+	}
+}
+
+// RemoveFeed takes the feed out of its folder, which is returned (nil if the
+// feed wasn't in any)
+func RemoveFeed(folderData *models.FolderData, feed *models.Feed) *models.FeedFolder {
+	for i := range folderData.Folders {
+		folder := &folderData.Folders[i]
+		for j, f := range folder.Feeds {
+			if f == feed {
+				folder.Feeds = append(folder.Feeds[:j], folder.Feeds[j+1:]...)
+				return folder
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"github.com/rzinak/core-rss/internal/models"
+	"golang.org/x/net/publicsuffix"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// getFollowing gets the feed following redirects like http.Get does, with
+// its credentials. It also returns where the chain of permanent redirects
+// (301/308) from the start ends, empty when there was none. Once a temporary
+// redirect shows up the rest of the chain doesn't count, it can change anytime
+func getFollowing(ctx context.Context, feedUrl string, auth *models.FeedAuth) (*http.Response, string, error) {
+	shared, err := clientFor(auth)
+	if err != nil {
+		return nil, "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedUrl, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := authorize(req, auth); err != nil {
+		return nil, "", err
+	}
+
+	permanentUrl := ""
+	temporary := false
+	client := *shared
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return fmt.Errorf("stopped after 10 redirects")
+		}
+		code := req.Response.StatusCode
+		if code != http.StatusMovedPermanently && code != http.StatusPermanentRedirect {
+			temporary = true
+		}
+		if !temporary {
+			permanentUrl = req.URL.String()
+		}
+		stripAuth(req, via, auth)
+		logToFile(fmt.Sprintf("redirect %d: %s -> %s", code, via[len(via)-1].URL, req.URL))
+		return nil
+	}
+
+	resp, err := client.Do(req)
+	return resp, permanentUrl, err
+}
+
+// selfLink is the url the feed says it lives at, from <atom:link rel="self">
+func selfLink(feed *models.Feed) string {
+	for _, link := range feed.Links {
+		if strings.EqualFold(link.Rel, "self") && link.Href != "" {
+			parsed, err := url.Parse(link.Href)
+			if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+				return ""
+			}
+			return link.Href
+		}
+	}
+	return ""
+}
+
+// detectMove sets fetched.Move when the feed at feedUrl now lives somewhere
+// else, either because of permanent redirects or because its self link says
+// so. Links that only differ in the scheme, www. or a trailing slash don't
+// count
+func detectMove(fetched *models.Feed, feedUrl, permanentUrl string) {
+	switch {
+	case permanentUrl != "" && permanentUrl != feedUrl:
+		fetched.Move = &models.FeedMove{URL: permanentUrl, Permanent: true}
+	case permanentUrl == "":
+		self := selfLink(fetched)
+		if self != "" && CanonicalLink(self) != CanonicalLink(feedUrl) {
+			fetched.Move = &models.FeedMove{URL: self}
+		}
+	}
+
+	if fetched.Move != nil {
+		logToFile(fmt.Sprintf("feed %s moved to %s (permanent redirect: %v)", feedUrl, fetched.Move.URL, fetched.Move.Permanent))
+	}
+}
+
+// SameSite reports whether two urls are on the same host or at least under
+// the same registrable domain, like feeds.example.com and www.example.com
+func SameSite(a, b string) bool {
+	parsedA, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	parsedB, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	hostA, hostB := strings.ToLower(parsedA.Hostname()), strings.ToLower(parsedB.Hostname())
+	if hostA == hostB {
+		return true
+	}
+	domainA, err := publicsuffix.EffectiveTLDPlusOne(hostA)
+	if err != nil {
+		return false
+	}
+	domainB, err := publicsuffix.EffectiveTLDPlusOne(hostB)
+	return err == nil && domainA == domainB
+}
@@ -0,0 +1,227 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/rzinak/core-rss/internal/models"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// a poor man's readability: paragraphs give points to their parents, the
+// node with the most points is the article. Class names and ids help to tell
+// the content apart from comments, sidebars and so on
+
+var (
+	positiveHints = regexp.MustCompile(`(?i)article|body|content|entry|main|page|post|text|blog|story`)
+	negativeHints = regexp.MustCompile(`(?i)comment|meta|footer|footnote|sidebar|widget|share|social|related|nav|menu|header|promo|banner|ad-|ads|sponsor|popup|cookie|subscribe|newsletter`)
+)
+
+// tags that never hold article content
+var junkTags = map[string]bool{
+	"script": true, "style": true, "noscript": true, "nav": true, "aside": true, "form": true,
+	"footer": true, "header": true, "iframe": true, "button": true, "input": true, "select": true,
+	"svg": true, "template": true,
+}
+
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			collect(c)
+		}
+	}
+	collect(n)
+	return b.String()
+}
+
+func classWeight(n *html.Node) float64 {
+	weight := 0.0
+	for _, a := range n.Attr {
+		if a.Key != "class" && a.Key != "id" {
+			continue
+		}
+		if negativeHints.MatchString(a.Val) {
+			weight -= 25
+		}
+		if positiveHints.MatchString(a.Val) {
+			weight += 25
+		}
+	}
+	return weight
+}
+
+// linkDensity is how much of the text of a node is inside links, menus and
+// lists of related posts are mostly links
+func linkDensity(n *html.Node) float64 {
+	total := len(nodeText(n))
+	if total == 0 {
+		return 0
+	}
+	linked := 0
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "a" {
+			linked += len(nodeText(n))
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			collect(c)
+		}
+	}
+	collect(n)
+	return float64(linked) / float64(total)
+}
+
+func removeJunk(n *html.Node) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.CommentNode || (c.Type == html.ElementNode && junkTags[c.Data]) {
+			n.RemoveChild(c)
+		} else if c.Type == html.ElementNode && classWeight(c) < 0 && linkDensity(c) > 0.3 {
+			n.RemoveChild(c)
+		} else {
+			removeJunk(c)
+		}
+		c = next
+	}
+}
+
+// ExtractArticle finds the main content of a web page and returns its html
+func ExtractArticle(page *html.Node) (string, error) {
+	removeJunk(page)
+
+	scores := map[*html.Node]float64{}
+	var candidates []*html.Node
+	var score func(*html.Node)
+	score = func(n *html.Node) {
+		if n.Type == html.ElementNode && (n.Data == "p" || n.Data == "pre" || n.Data == "td") {
+			text := strings.TrimSpace(nodeText(n))
+			if len(text) >= 25 {
+				points := 1 + float64(strings.Count(text, ",")) + min(float64(len(text))/100, 3)
+				// the parent gets all the points, the grandparent half of them
+				if parent := n.Parent; parent != nil {
+					if _, ok := scores[parent]; !ok {
+						scores[parent] = classWeight(parent)
+						candidates = append(candidates, parent)
+					}
+					scores[parent] += points
+					if grandparent := parent.Parent; grandparent != nil {
+						if _, ok := scores[grandparent]; !ok {
+							scores[grandparent] = classWeight(grandparent)
+							candidates = append(candidates, grandparent)
+						}
+						scores[grandparent] += points / 2
+					}
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			score(c)
+		}
+	}
+	score(page)
+
+	var best *html.Node
+	bestScore := 0.0
+	for _, candidate := range candidates {
+		// lots of links means navigation, not text
+		s := scores[candidate] * (1 - linkDensity(candidate))
+		if best == nil || s > bestScore {
+			best, bestScore = candidate, s
+		}
+	}
+	if best == nil {
+		return "", fmt.Errorf("couldn't find the article content")
+	}
+
+	var buf bytes.Buffer
+	for c := best.FirstChild; c != nil; c = c.NextSibling {
+		if err := html.Render(&buf, c); err != nil {
+			return "", err
+		}
+	}
+	return buf.String(), nil
+}
+
+// FetchFullArticle downloads the page an item links to and extracts its
+// main content, for feeds that only publish a summary
+func FetchFullArticle(link string) (string, error) {
+	if link == "" {
+		return "", fmt.Errorf("item has no link")
+	}
+	if IsSmallWebLink(link) {
+		return fetchSmallWebArticle(link)
+	}
+
+	resp, err := httpClient.Get(link)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status fetching %s: %s", link, resp.Status)
+	}
+
+	reader, err := charset.NewReader(resp.Body, resp.Header.Get("Content-Type"))
+	if err != nil {
+		return "", err
+	}
+
+	page, err := html.Parse(reader)
+	if err != nil {
+		return "", err
+	}
+	return ExtractArticle(page)
+}
+
+// SetFullContent keeps the extracted article in the cache with the item
+func SetFullContent(cache *models.ItemCache, item models.Item, content string) bool {
+	cachedItem := findCached(cache, item)
+	if cachedItem == nil {
+		return false
+	}
+	cachedItem.FullContent = content
+	return true
+}
+
+// IsSmallWebLink reports whether the link is a gemini or gopher one
+func IsSmallWebLink(link string) bool {
+	lower := strings.ToLower(link)
+	return strings.HasPrefix(lower, "gemini://") || strings.HasPrefix(lower, "gopher://")
+}
+
+// fetchSmallWebArticle gets a gemini or gopher page as html, there's no
+// clutter to remove around the content there
+func fetchSmallWebArticle(link string) (string, error) {
+	if strings.HasPrefix(strings.ToLower(link), "gopher://") {
+		body, itemType, err := gopherGet(context.Background(), link)
+		if err != nil {
+			return "", err
+		}
+		return gopherToHTML(body, itemType), nil
+	}
+
+	body, mime, _, err := geminiGet(context.Background(), link, nil)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case strings.HasPrefix(mime, "text/gemini"):
+		return gemtextToHTML(string(body), link), nil
+	case strings.HasPrefix(mime, "text/html"):
+		return string(body), nil
+	case strings.HasPrefix(mime, "text/"):
+		return "<pre>" + html.EscapeString(string(body)) + "</pre>", nil
+	}
+	return "", fmt.Errorf("can't show %s documents", mime)
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"github.com/rzinak/core-rss/internal/config"
+	"github.com/rzinak/core-rss/internal/models"
+	"github.com/rzinak/core-rss/pkg/utils"
+	"os"
+	"time"
+)
+
+// PurgeReport says what a cleanup removed from the cache
+type PurgeReport struct {
+	Removed     []models.Item  // so they can be dropped from the search index too
+	PerFeed     map[string]int // feed title -> removed items
+	BytesBefore int64
+	BytesAfter  int64
+}
+
+func retentionFor(feed *models.Feed, global models.Retention) models.Retention {
+	if feed != nil && feed.Retention != nil {
+		return *feed.Retention
+	}
+	return global
+}
+
+// PurgeCache enforces the retention policies on the cache. Starred items are
+// never purged, and neither are unread ones if the policy says so. Feeds we
+// are not subscribed to anymore only keep those. folderData has to be the one
+// loaded from feeds.json, with a made up one every feed looks unsubscribed
+func PurgeCache(cache *models.ItemCache, folderData *models.FolderData, global models.Retention) *PurgeReport {
+	report := &PurgeReport{PerFeed: map[string]int{}}
+
+	subscribed := map[string]*models.Feed{}
+	for _, folder := range folderData.Folders {
+		for _, feed := range folder.Feeds {
+			subscribed[feed.URL] = feed
+		}
+	}
+
+	now := time.Now()
+	for url, cached := range cache.Feeds {
+		feed, ok := subscribed[url]
+		policy := retentionFor(feed, global)
+
+		kept := make([]models.Item, 0, len(cached.Items))
+		keptPurgeable := 0
+		for _, item := range cached.Items {
+			keep := true
+			switch {
+			case item.Starred:
+			case policy.KeepUnread && !item.Read:
+			case !ok:
+				keep = false
+			default:
+				published := utils.ParseDate(item.PubDate)
+				if policy.MaxAgeDays > 0 && !published.IsZero() && now.Sub(published) > time.Duration(policy.MaxAgeDays)*24*time.Hour {
+					keep = false
+				} else if policy.MaxItems > 0 && keptPurgeable >= policy.MaxItems {
+					// items are newest first, so the ones over the limit are the oldest
+					keep = false
+				} else {
+					keptPurgeable++
+				}
+			}
+
+			if keep {
+				kept = append(kept, item)
+			} else {
+				report.Removed = append(report.Removed, item)
+				report.PerFeed[cached.Title]++
+			}
+		}
+
+		cached.Items = kept
+		if len(kept) == 0 {
+			delete(cache.Feeds, url)
+		}
+	}
+
+	return report
+}
+
+func cacheSize() int64 {
+	filePath, err := config.Path("cache.json")
+	if err != nil {
+		return 0
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// VacuumCache loads the cache from disk, purges it and saves it back, it's
+// what the vacuum command runs
+func VacuumCache(folderData *models.FolderData, global models.Retention) (*PurgeReport, error) {
+	cache, err := LoadCache()
+	if err != nil {
+		return nil, err
+	}
+
+	before := cacheSize()
+	report := PurgeCache(cache, folderData, global)
+	if err := SaveCache(cache); err != nil {
+		return nil, err
+	}
+	report.BytesBefore = before
+	report.BytesAfter = cacheSize()
+	return report, nil
+}
+
+// StartCleanup calls cleanup every interval until the returned stop function
+// is called. cleanup is responsible for running the purge wherever it's safe
+// to touch the cache (the ui goroutine, for the tui)
+func StartCleanup(interval time.Duration, cleanup func()) (stop func()) {
+	return every(interval, cleanup)
+}
+
+// StartRefresh calls refresh every interval until the returned stop function
+// is called, for refreshing the feeds in the background
+func StartRefresh(interval time.Duration, refresh func()) (stop func()) {
+	return every(interval, refresh)
+}
+
+func every(interval time.Duration, fn func()) (stop func()) {
+	ticker := time.NewTicker(interval)
+	done := make(chan struct{})
+
+	go func() {
+		for {
+			select {
+			case <-ticker.C:
+				fn()
+			case <-done:
+				ticker.Stop()
+				return
+			}
+		}
+	}()
+
+	return func() { close(done) }
+}
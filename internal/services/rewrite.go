@@ -0,0 +1,227 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// a rewriter turns the url of a page into the url of its feed, it returns ""
+// when the url isn't one it knows
+type rewriter func(ctx context.Context, u *url.URL) (string, error)
+
+// the first one that knows the url wins, mastodon goes last since it's only
+// guessed from the path
+var rewriters = []rewriter{youtubeFeed, redditFeed, githubFeed, mastodonFeed}
+
+// RewriteFeedURL returns the feed of a well known site's page (a youtube
+// channel, a subreddit, a mastodon account, a github repo), or the url as is
+// when there's nothing to rewrite
+func RewriteFeedURL(ctx context.Context, pageUrl string) (string, error) {
+	if user, host, ok := mastodonHandle(pageUrl); ok {
+		return webfingerFeed(ctx, user, host)
+	}
+	u, err := url.Parse(pageUrl)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return pageUrl, nil
+	}
+	for _, rewrite := range rewriters {
+		feedUrl, err := rewrite(ctx, u)
+		if err != nil {
+			return "", err
+		}
+		if feedUrl != "" {
+			return feedUrl, nil
+		}
+	}
+	return pageUrl, nil
+}
+
+// pathParts splits the path of u, without empty parts
+func pathParts(u *url.URL) []string {
+	var parts []string
+	for _, part := range strings.Split(u.Path, "/") {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+var youtubeChannelId = regexp.MustCompile(`(?:"channelId":"|"externalId":"|itemprop="(?:channelId|identifier)" content="|youtube\.com/channel/)(UC[\w-]{22})`)
+
+// youtubeFeed handles /channel/ID, playlists and the channel pages that only
+// have a name (/@handle, /c/name, /user/name), which have to be fetched to
+// find the channel id
+func youtubeFeed(ctx context.Context, u *url.URL) (string, error) {
+	host := strings.TrimPrefix(strings.TrimPrefix(u.Hostname(), "www."), "m.")
+	if host != "youtube.com" {
+		return "", nil
+	}
+	parts := pathParts(u)
+	if len(parts) == 0 || parts[0] == "feeds" {
+		return "", nil
+	}
+
+	const feeds = "https://www.youtube.com/feeds/videos.xml"
+	switch {
+	case parts[0] == "playlist" && u.Query().Get("list") != "":
+		return feeds + "?playlist_id=" + url.QueryEscape(u.Query().Get("list")), nil
+	case parts[0] == "channel" && len(parts) > 1:
+		return feeds + "?channel_id=" + url.QueryEscape(parts[1]), nil
+	case strings.HasPrefix(parts[0], "@"), (parts[0] == "c" || parts[0] == "user") && len(parts) > 1:
+	default:
+		return "", nil
+	}
+
+	channelPage := "https://www.youtube.com/" + parts[0]
+	if !strings.HasPrefix(parts[0], "@") {
+		channelPage += "/" + parts[1]
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, channelPage, nil)
+	if err != nil {
+		return "", err
+	}
+	// without it, visitors from the EU get the cookie consent page instead
+	req.AddCookie(&http.Cookie{Name: "CONSENT", Value: "YES+"})
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", &StatusError{URL: channelPage, Code: resp.StatusCode, Status: resp.Status}
+	}
+
+	page, err := io.ReadAll(io.LimitReader(resp.Body, maxDocumentSize))
+	if err != nil {
+		return "", err
+	}
+	match := youtubeChannelId.FindSubmatch(page)
+	if match == nil {
+		return "", fmt.Errorf("no channel id found in %s", channelPage)
+	}
+	return feeds + "?channel_id=" + string(match[1]), nil
+}
+
+// redditFeed handles subreddits, users and their listings (/r/golang/top)
+func redditFeed(ctx context.Context, u *url.URL) (string, error) {
+	host := u.Hostname()
+	if host != "reddit.com" && !strings.HasSuffix(host, ".reddit.com") {
+		return "", nil
+	}
+	parts := pathParts(u)
+	if len(parts) < 2 || (parts[0] != "r" && parts[0] != "u" && parts[0] != "user") {
+		return "", nil
+	}
+	if strings.HasSuffix(parts[len(parts)-1], ".rss") {
+		return "", nil
+	}
+	feedUrl := "https://www.reddit.com/" + strings.Join(parts, "/") + "/.rss"
+	if u.RawQuery != "" {
+		// the sorting of listings (?t=week) works on the feed too
+		feedUrl += "?" + u.RawQuery
+	}
+	return feedUrl, nil
+}
+
+// githubFeed handles repos (their releases) and their tags
+func githubFeed(ctx context.Context, u *url.URL) (string, error) {
+	if strings.TrimPrefix(u.Hostname(), "www.") != "github.com" {
+		return "", nil
+	}
+	parts := pathParts(u)
+	if len(parts) < 2 {
+		return "", nil
+	}
+	repo := "https://github.com/" + parts[0] + "/" + strings.TrimSuffix(parts[1], ".git")
+	switch {
+	case len(parts) == 2, len(parts) == 3 && parts[2] == "releases":
+		return repo + "/releases.atom", nil
+	case len(parts) == 3 && parts[2] == "tags":
+		return repo + "/tags.atom", nil
+	}
+	return "", nil
+}
+
+// mastodonFeed handles profiles (/@user) on any instance. Remote accounts
+// (/@user@other.host) are looked up on their own instance, the one showing
+// them only has their recent posts
+func mastodonFeed(ctx context.Context, u *url.URL) (string, error) {
+	parts := pathParts(u)
+	if len(parts) != 1 || !strings.HasPrefix(parts[0], "@") || strings.HasSuffix(parts[0], ".rss") {
+		return "", nil
+	}
+	if user, host, ok := mastodonHandle(parts[0]); ok {
+		feedUrl, err := webfingerFeed(ctx, user, host)
+		if err != nil && ctx.Err() == nil {
+			// it's only a guess from the path, the page can still be a feed
+			logToFile(fmt.Sprintf("looking up %s: %v", parts[0], err))
+			return "", nil
+		}
+		return feedUrl, err
+	}
+	if strings.Contains(parts[0][1:], "@") {
+		return "", nil
+	}
+	return u.Scheme + "://" + u.Host + "/" + parts[0] + ".rss", nil
+}
+
+// mastodonHandle splits a handle like @user@host
+func mastodonHandle(handle string) (string, string, bool) {
+	if !strings.HasPrefix(handle, "@") {
+		return "", "", false
+	}
+	user, host, ok := strings.Cut(handle[1:], "@")
+	if !ok || user == "" || host == "" || strings.ContainsAny(user, "/?#: ") || strings.ContainsAny(host, "@/?# ") {
+		return "", "", false
+	}
+	return user, host, true
+}
+
+// webfingerFeed asks the instance of an account where its profile is, the
+// feed is next to it
+func webfingerFeed(ctx context.Context, user, host string) (string, error) {
+	lookup := "https://" + host + "/.well-known/webfinger?resource=" + url.QueryEscape("acct:"+user+"@"+host)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, lookup, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/jrd+json, application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", &StatusError{URL: lookup, Code: resp.StatusCode, Status: resp.Status}
+	}
+
+	var account struct {
+		Links []struct {
+			Rel  string `json:"rel"`
+			Href string `json:"href"`
+		} `json:"links"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxDocumentSize)).Decode(&account); err != nil {
+		return "", fmt.Errorf("reading the webfinger of @%s@%s: %v", user, host, err)
+	}
+	for _, link := range account.Links {
+		if link.Rel != "http://webfinger.net/rel/profile-page" {
+			continue
+		}
+		profile, err := url.Parse(link.Href)
+		if err != nil || (profile.Scheme != "http" && profile.Scheme != "https") {
+			continue
+		}
+		if parts := pathParts(profile); len(parts) == 1 && strings.HasPrefix(parts[0], "@") {
+			return profile.Scheme + "://" + profile.Host + "/" + parts[0] + ".rss", nil
+		}
+	}
+	return "", fmt.Errorf("no mastodon profile found for @%s@%s", user, host)
+}
@@ -0,0 +1,42 @@
+package services
+
+import (
+	"github.com/rzinak/core-rss/internal/models"
+	"github.com/rzinak/core-rss/pkg/utils"
+	"sort"
+	"time"
+)
+
+// RiverItems merges the cached items of every feed into a single list, newest
+// first. keep can be nil to get everything
+func RiverItems(cache *models.ItemCache, feeds []*models.Feed, keep func(models.Item) bool) []models.Item {
+	var items []models.Item
+	for _, feed := range feeds {
+		for _, item := range CachedItems(cache, feed.URL) {
+			if keep == nil || keep(item) {
+				items = append(items, item)
+			}
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return utils.ParseDate(items[i].PubDate).After(utils.ParseDate(items[j].PubDate))
+	})
+	return items
+}
+
+func IsUnread(item models.Item) bool {
+	return !item.Read
+}
+
+// IsToday reports whether the item was published today (local time), items
+// without a date we can understand are left out
+func IsToday(item models.Item) bool {
+	published := utils.ParseDate(item.PubDate)
+	if published.IsZero() {
+		return false
+	}
+	y, m, d := published.Local().Date()
+	ty, tm, td := time.Now().Date()
+	return y == ty && m == tm && d == td
+}
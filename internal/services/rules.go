@@ -0,0 +1,159 @@
+package services
+
+import (
+	"fmt"
+	"github.com/rzinak/core-rss/internal/config"
+	"github.com/rzinak/core-rss/internal/models"
+	"regexp"
+	"strings"
+)
+
+// RuleOutcome is what the rules matching an item want done with it
+type RuleOutcome struct {
+	Hide     bool
+	MarkRead bool
+	Star     bool
+	Notify   bool
+	Color    string // empty when there's nothing to highlight
+}
+
+type compiledRule struct {
+	config.Rule
+	pattern *regexp.Regexp // nil for substring rules
+}
+
+// RuleSet holds the user rules ready to be matched
+type RuleSet struct {
+	rules []compiledRule
+}
+
+var ruleFields = map[string]bool{"title": true, "author": true, "category": true, "link": true, "content": true, "any": true}
+var ruleActions = map[string]bool{"hide": true, "read": true, "star": true, "highlight": true, "notify": true}
+
+// NewRuleSet validates and compiles the rules. Broken rules are skipped and
+// reported in the error, the valid ones are still used
+func NewRuleSet(rules []config.Rule) (*RuleSet, error) {
+	set := &RuleSet{}
+	var problems []string
+
+	for i, rule := range rules {
+		rule.Field = strings.ToLower(rule.Field)
+		rule.Action = strings.ToLower(rule.Action)
+		if rule.Field == "" {
+			rule.Field = "any"
+		}
+
+		if !ruleFields[rule.Field] {
+			problems = append(problems, fmt.Sprintf("rule %d: unknown field %q", i+1, rule.Field))
+			continue
+		}
+		if !ruleActions[rule.Action] {
+			problems = append(problems, fmt.Sprintf("rule %d: unknown action %q", i+1, rule.Action))
+			continue
+		}
+
+		compiled := compiledRule{Rule: rule}
+		if rule.Regex {
+			pattern, err := regexp.Compile("(?i)" + rule.Match)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("rule %d: %v", i+1, err))
+				continue
+			}
+			compiled.pattern = pattern
+		} else {
+			compiled.Match = strings.ToLower(rule.Match)
+		}
+		set.rules = append(set.rules, compiled)
+	}
+
+	if len(problems) > 0 {
+		return set, fmt.Errorf("invalid rules: %s", strings.Join(problems, "; "))
+	}
+	return set, nil
+}
+
+func ruleTexts(item models.Item, field string) []string {
+	switch field {
+	case "title":
+		return []string{item.Title}
+	case "author":
+		return []string{item.Author, item.Creator}
+	case "category":
+		return item.Categories
+	case "link":
+		return []string{item.Link}
+	case "content":
+		return []string{item.Content, item.Description}
+	}
+	texts := []string{item.Title, item.Author, item.Creator, item.Link, item.Content, item.Description}
+	return append(texts, item.Categories...)
+}
+
+func (r compiledRule) matches(item models.Item, folder string) bool {
+	if r.Folder != "" && r.Folder != folder {
+		return false
+	}
+	if r.Feed != "" && r.Feed != item.FeedURL {
+		return false
+	}
+
+	for _, text := range ruleTexts(item, r.Field) {
+		if r.pattern != nil {
+			if r.pattern.MatchString(text) {
+				return true
+			}
+		} else if strings.Contains(strings.ToLower(text), r.Match) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match runs every rule against the item, folder is the name of the folder
+// the item's feed is in
+func (rs *RuleSet) Match(item models.Item, folder string) RuleOutcome {
+	var outcome RuleOutcome
+	if rs == nil {
+		return outcome
+	}
+
+	for _, rule := range rs.rules {
+		if !rule.matches(item, folder) {
+			continue
+		}
+		switch rule.Action {
+		case "hide":
+			outcome.Hide = true
+		case "read":
+			outcome.MarkRead = true
+		case "star":
+			outcome.Star = true
+		case "notify":
+			outcome.Notify = true
+		case "highlight":
+			// first highlight wins, so more specific rules should come first
+			if outcome.Color == "" {
+				outcome.Color = rule.Color
+				if outcome.Color == "" {
+					outcome.Color = "yellow"
+				}
+			}
+		}
+	}
+	return outcome
+}
+
+// ApplyOnFetch sets the read and starred flags the rules ask for on freshly
+// fetched items. It has to run before CacheItems, which keeps the flags of the
+// items it already knows, so the rules only affect new items
+func (rs *RuleSet) ApplyOnFetch(items []models.Item, folder string) {
+	for i := range items {
+		outcome := rs.Match(items[i], folder)
+		if outcome.MarkRead {
+			items[i].Read = true
+		}
+		if outcome.Star {
+			items[i].Starred = true
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"github.com/rzinak/core-rss/internal/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var updatePeriods = map[string]time.Duration{
+	"hourly":  time.Hour,
+	"daily":   24 * time.Hour,
+	"weekly":  7 * 24 * time.Hour,
+	"monthly": 30 * 24 * time.Hour,
+	"yearly":  365 * 24 * time.Hour,
+}
+
+// CopySchedule keeps the polling wishes of a freshly fetched feed in the
+// stored one
+func CopySchedule(feed *models.Feed, fetched *models.Feed) {
+	feed.TTL = strings.TrimSpace(fetched.TTL)
+	feed.SkipHours = fetched.SkipHours
+	feed.SkipDays = fetched.SkipDays
+	feed.UpdatePeriod = strings.TrimSpace(fetched.UpdatePeriod)
+	feed.UpdateFrequency = strings.TrimSpace(fetched.UpdateFrequency)
+}
+
+// MinInterval is the least time the publisher wants between two fetches,
+// from <ttl> or sy:updatePeriod/sy:updateFrequency, whichever is longer
+func MinInterval(feed *models.Feed) time.Duration {
+	var interval time.Duration
+	if minutes, err := strconv.Atoi(feed.TTL); err == nil && minutes > 0 {
+		interval = time.Duration(minutes) * time.Minute
+	}
+
+	if period, ok := updatePeriods[strings.ToLower(feed.UpdatePeriod)]; ok {
+		frequency, err := strconv.Atoi(feed.UpdateFrequency)
+		if err != nil || frequency < 1 {
+			frequency = 1
+		}
+		interval = max(interval, period/time.Duration(frequency))
+	}
+	return interval
+}
+
+// skipped says whether now falls in the <skipHours> or <skipDays> of the
+// feed, they're in GMT
+func skipped(feed *models.Feed, now time.Time) bool {
+	now = now.UTC()
+	for _, hour := range feed.SkipHours {
+		if h, err := strconv.Atoi(strings.TrimSpace(hour)); err == nil && h%24 == now.Hour() {
+			return true
+		}
+	}
+	for _, day := range feed.SkipDays {
+		if strings.EqualFold(strings.TrimSpace(day), now.Weekday().String()) {
+			return true
+		}
+	}
+	return false
+}
+
+// DueForRefresh says whether a background refresh should fetch the feed now:
+// dead feeds never are, the others when the publisher is fine with it
+func DueForRefresh(feed *models.Feed, now time.Time) bool {
+	if feed.Status != nil && feed.Status.Dead {
+		return false
+	}
+	if skipped(feed, now) {
+		return false
+	}
+	if feed.Status == nil || feed.Status.LastFetch.IsZero() {
+		return true
+	}
+	return now.Sub(feed.Status.LastFetch) >= MinInterval(feed)
+}
+
+// AliveFeeds leaves the dead feeds out
+func AliveFeeds(feeds []*models.Feed) []*models.Feed {
+	alive := make([]*models.Feed, 0, len(feeds))
+	for _, feed := range feeds {
+		if feed.Status == nil || !feed.Status.Dead {
+			alive = append(alive, feed)
+		}
+	}
+	return alive
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/andybalholm/cascadia"
+	"github.com/rzinak/core-rss/internal/models"
+	"github.com/rzinak/core-rss/pkg/utils"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/charset"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// scrapeSource makes a feed out of a web page with the selectors in the
+// feed's Scrape rule
+type scrapeSource struct{}
+
+func (scrapeSource) Open(ctx context.Context, feed *models.Feed) (*Document, error) {
+	if feed.Scrape == nil || feed.Scrape.Item == "" {
+		return nil, fmt.Errorf("%s has no scrape rule", feed.URL)
+	}
+
+	doc, err := httpSource{}.Open(ctx, feed)
+	if err != nil {
+		return nil, err
+	}
+	defer doc.Body.Close()
+
+	reader, err := charset.NewReader(doc.Body, "")
+	if err != nil {
+		return nil, err
+	}
+	page, err := html.Parse(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	scraped, err := ScrapePage(page, feed.URL, *feed.Scrape)
+	if err != nil {
+		return nil, err
+	}
+	return &Document{Feed: scraped, PermanentURL: doc.PermanentURL}, nil
+}
+
+// fieldSelector is a selector with an optional @attr at the end
+type fieldSelector struct {
+	selector cascadia.Matcher // nil means the item itself
+	attr     string
+}
+
+func compileField(field string) (fieldSelector, error) {
+	var f fieldSelector
+	if at := strings.LastIndex(field, "@"); at >= 0 && !strings.ContainsAny(field[at:], "]") {
+		field, f.attr = field[:at], field[at+1:]
+	}
+	field = strings.TrimSpace(field)
+	if field == "" {
+		return f, nil
+	}
+	selector, err := cascadia.ParseGroup(field)
+	if err != nil {
+		return f, err
+	}
+	f.selector = selector
+	return f, nil
+}
+
+// find returns the node the field points to inside item, nil if there's none
+func (f fieldSelector) find(item *html.Node) *html.Node {
+	if f.selector == nil {
+		return item
+	}
+	if f.selector.Match(item) {
+		return item
+	}
+	return cascadia.Query(item, f.selector)
+}
+
+// text is the attribute, or the text, of the node the field points to
+func (f fieldSelector) text(item *html.Node) string {
+	n := f.find(item)
+	if n == nil {
+		return ""
+	}
+	if f.attr != "" {
+		for _, a := range n.Attr {
+			if a.Key == f.attr {
+				return strings.TrimSpace(a.Val)
+			}
+		}
+		return ""
+	}
+	return strings.Join(strings.Fields(nodeText(n)), " ")
+}
+
+// ScrapePage builds a feed from a page, base is the page url (for relative
+// links)
+func ScrapePage(page *html.Node, base string, rule models.ScrapeRule) (*models.Feed, error) {
+	itemSelector, err := cascadia.ParseGroup(rule.Item)
+	if err != nil {
+		return nil, err
+	}
+
+	titleField := rule.Title
+	if titleField == "" {
+		titleField = "h1, h2, h3, h4, a"
+	}
+	linkField := rule.Link
+	if linkField == "" {
+		linkField = "a"
+	}
+
+	fields := map[string]*fieldSelector{}
+	for name, field := range map[string]string{"title": titleField, "link": linkField, "date": rule.Date, "content": rule.Content} {
+		if name == "date" && field == "" {
+			continue
+		}
+		compiled, err := compileField(field)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		if name == "link" && compiled.attr == "" {
+			compiled.attr = "href"
+		}
+		fields[name] = &compiled
+	}
+
+	baseUrl, _ := url.Parse(base)
+	feed := &models.Feed{URL: base}
+	feed.Title = base
+	if title := cascadia.Query(page, cascadia.MustCompile("title")); title != nil && strings.TrimSpace(nodeText(title)) != "" {
+		feed.Title = strings.TrimSpace(nodeText(title))
+	}
+
+	for _, node := range cascadia.QueryAll(page, itemSelector) {
+		item := models.Item{Title: fields["title"].text(node)}
+
+		if link := fields["link"].text(node); link != "" {
+			item.Link = link
+			if baseUrl != nil {
+				if resolved, err := baseUrl.Parse(link); err == nil {
+					item.Link = resolved.String()
+				}
+			}
+		}
+
+		if dateField := fields["date"]; dateField != nil {
+			item.PubDate = dateField.text(node)
+			// the renderer and the sorting understand rfc 1123 best
+			if date := utils.ParseDate(item.PubDate); !date.IsZero() {
+				item.PubDate = date.Format(time.RFC1123Z)
+			}
+		}
+
+		if content := fields["content"].find(node); content != nil {
+			var buf bytes.Buffer
+			for c := content.FirstChild; c != nil; c = c.NextSibling {
+				html.Render(&buf, c)
+			}
+			item.Content = buf.String()
+		}
+
+		if item.Title == "" && item.Link == "" {
+			continue
+		}
+		// pages often link every item to the same place, the title tells them apart
+		item.GUID = strings.TrimSpace(item.Link + " " + item.Title)
+		feed.Items = append(feed.Items, item)
+	}
+
+	if len(feed.Items) == 0 {
+		return feed, fmt.Errorf("no items matched %q", rule.Item)
+	}
+	return feed, nil
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"github.com/rzinak/core-rss/internal/models"
+	"golang.org/x/net/html"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const scrapeFixture = `<html><head><title>Changelog</title></head><body>
+<div id="releases">
+	<article class="release">
+		<h2><a href="/v2">Version 2.0</a></h2>
+		<time datetime="2024-03-01T10:00:00Z">March 1</time>
+		<div class="notes"><p>New <b>things</b></p></div>
+	</article>
+	<article class="release">
+		<h3>Version 1.1</h3>
+		<a href="https://other.example.com/v1.1" title="mail@me">notes</a>
+		<div class="notes"><p>Fixes</p></div>
+	</article>
+	<article class="release"><div class="notes">nothing to call it</div></article>
+</div>
+</body></html>`
+
+func TestCompileField(t *testing.T) {
+	tests := []struct {
+		field    string
+		selector bool
+		attr     string
+	}{
+		{"time@datetime", true, "datetime"},
+		{"@href", false, "href"},
+		{"", false, ""},
+		{".notes", true, ""},
+		{"a[href$='@x']", true, ""},
+		{"a[href$='@x']@title", true, "title"},
+		{`a[title="a@b"] , h2@data-id`, true, "data-id"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			f, err := compileField(tt.field)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (f.selector != nil) != tt.selector || f.attr != tt.attr {
+				t.Errorf("got selector %v and attr %q, want %v and %q", f.selector != nil, f.attr, tt.selector, tt.attr)
+			}
+		})
+	}
+
+	if _, err := compileField("a[href@x"); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestScrapePage(t *testing.T) {
+	page, err := html.Parse(strings.NewReader(scrapeFixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type scraped struct{ Title, Link, PubDate, Content string }
+	tests := []struct {
+		name string
+		rule models.ScrapeRule
+		want []scraped
+	}{
+		{
+			"defaults",
+			models.ScrapeRule{Item: "article"},
+			[]scraped{
+				{"Version 2.0", "https://example.com/v2", "", ""},
+				{"Version 1.1", "https://other.example.com/v1.1", "", ""},
+			},
+		},
+		{
+			"every field",
+			models.ScrapeRule{Item: "#releases > article", Title: "h2, h3", Date: "time@datetime", Content: ".notes"},
+			[]scraped{
+				{"Version 2.0", "https://example.com/v2", "Fri, 01 Mar 2024 10:00:00 +0000", "<p>New <b>things</b></p>"},
+				{"Version 1.1", "https://other.example.com/v1.1", "", "<p>Fixes</p>"},
+			},
+		},
+		{
+			// selectors copied from the devtools
+			"siblings and pseudo classes",
+			models.ScrapeRule{Item: "article:nth-child(odd), article + article:not(:last-child)", Title: "h2, h3 ~ a"},
+			[]scraped{
+				{"Version 2.0", "https://example.com/v2", "", ""},
+				{"notes", "https://other.example.com/v1.1", "", ""},
+			},
+		},
+		{
+			"attribute with an @ in the link",
+			models.ScrapeRule{Item: "article", Title: "@class", Link: "a[title='mail@me']"},
+			[]scraped{
+				{"release", "", "", ""},
+				{"release", "https://other.example.com/v1.1", "", ""},
+				{"release", "", "", ""},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed, err := ScrapePage(page, "https://example.com/changelog", tt.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if feed.Title != "Changelog" {
+				t.Errorf("feed title %q", feed.Title)
+			}
+
+			var got []scraped
+			for _, item := range feed.Items {
+				// without a content selector it's the whole item
+				content := ""
+				if tt.rule.Content != "" {
+					content = item.Content
+				}
+				got = append(got, scraped{item.Title, item.Link, item.PubDate, content})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScrapePageNoItems(t *testing.T) {
+	page, _ := html.Parse(strings.NewReader(scrapeFixture))
+	if _, err := ScrapePage(page, "https://example.com/", models.ScrapeRule{Item: ".missing"}); err == nil {
+		t.Error("expected an error when nothing matches")
+	}
+	if _, err := ScrapePage(page, "https://example.com/", models.ScrapeRule{Item: "article", Date: "time["}); err == nil {
+		t.Error("expected an error for a bad selector")
+	}
+}
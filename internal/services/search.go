@@ -0,0 +1,156 @@
+package services
+
+import (
+	"github.com/jaytaylor/html2text"
+	"github.com/rzinak/core-rss/internal/models"
+	"github.com/rzinak/core-rss/pkg/utils"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// SearchIndex is an inverted index (word -> items) over the cached items,
+// it lives in memory and is rebuilt from the cache on startup
+type SearchIndex struct {
+	mu       sync.RWMutex
+	postings map[string]map[string]struct{}
+	items    map[string]models.Item
+	tokens   map[string][]string // item -> words, so removing doesn't scan everything
+}
+
+func NewSearchIndex() *SearchIndex {
+	return &SearchIndex{
+		postings: map[string]map[string]struct{}{},
+		items:    map[string]models.Item{},
+		tokens:   map[string][]string{},
+	}
+}
+
+// BuildSearchIndex indexes every item in the cache
+func BuildSearchIndex(cache *models.ItemCache) *SearchIndex {
+	index := NewSearchIndex()
+	for _, cached := range cache.Feeds {
+		index.Add(cached.Items...)
+	}
+	return index
+}
+
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+func indexKey(item models.Item) string {
+	return item.FeedURL + "\x00" + ItemKey(item)
+}
+
+// ItemText returns the plain text of an item body, used for indexing
+func ItemText(item models.Item) string {
+	body := item.Content
+	if body == "" {
+		body = item.Description
+	}
+	text, err := html2text.FromString(body)
+	if err != nil {
+		return body
+	}
+	return text
+}
+
+// Add indexes the items, items already in the index are replaced
+func (idx *SearchIndex) Add(items ...models.Item) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	for _, item := range items {
+		key := indexKey(item)
+		if _, ok := idx.items[key]; ok {
+			idx.remove(key)
+		}
+		idx.items[key] = item
+
+		text := strings.Join([]string{item.Title, item.Author, item.Creator, ItemText(item)}, " ")
+		tokens := tokenize(text)
+		idx.tokens[key] = tokens
+		for _, token := range tokens {
+			if idx.postings[token] == nil {
+				idx.postings[token] = map[string]struct{}{}
+			}
+			idx.postings[token][key] = struct{}{}
+		}
+	}
+}
+
+// Remove drops the items from the index
+func (idx *SearchIndex) Remove(items ...models.Item) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	for _, item := range items {
+		idx.remove(indexKey(item))
+	}
+}
+
+func (idx *SearchIndex) remove(key string) {
+	for _, token := range idx.tokens[key] {
+		if keys, ok := idx.postings[token]; ok {
+			delete(keys, key)
+			if len(keys) == 0 {
+				delete(idx.postings, token)
+			}
+		}
+	}
+	delete(idx.items, key)
+	delete(idx.tokens, key)
+}
+
+// Search returns the items containing every word of the query, newest first.
+// The last word is matched as a prefix so results show up while typing
+func (idx *SearchIndex) Search(query string) []models.Item {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	tokens := tokenize(query)
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	var matches map[string]struct{}
+	for i, token := range tokens {
+		found := map[string]struct{}{}
+		if i == len(tokens)-1 {
+			for indexed, keys := range idx.postings {
+				if strings.HasPrefix(indexed, token) {
+					for key := range keys {
+						found[key] = struct{}{}
+					}
+				}
+			}
+		} else {
+			for key := range idx.postings[token] {
+				found[key] = struct{}{}
+			}
+		}
+
+		if matches == nil {
+			matches = found
+			continue
+		}
+		for key := range matches {
+			if _, ok := found[key]; !ok {
+				delete(matches, key)
+			}
+		}
+	}
+
+	results := make([]models.Item, 0, len(matches))
+	for key := range matches {
+		results = append(results, idx.items[key])
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return utils.ParseDate(results[i].PubDate).After(utils.ParseDate(results[j].PubDate))
+	})
+	return results
+}
@@ -1,16 +1,18 @@
 package services
 
 import (
+	"context"
 	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
+	"github.com/rzinak/core-rss/internal/config"
 	"github.com/rzinak/core-rss/internal/models"
 	"github.com/rzinak/core-rss/pkg/utils"
 	"golang.org/x/net/html/charset"
 	"io"
 	"net/http"
 	"os"
-	"path/filepath"
 )
 
 func logToFile(message string) {
@@ -60,14 +62,11 @@ func LoadFeeds(folder *models.FeedFolder) error {
 }
 
 func LoadFolders() (*models.FolderData, error) {
-	configDir, err := os.UserConfigDir()
+	filePath, err := config.Path("feeds.json")
 	if err != nil {
 		return nil, err
 	}
 
-	appDir := filepath.Join(configDir, "core-rss")
-	filePath := filepath.Join(appDir, "feeds.json")
-
 	file, err := os.Open(filePath)
 
 	if err != nil {
@@ -115,59 +114,105 @@ func LoadFolders() (*models.FolderData, error) {
 }
 
 func SaveFolders(data *models.FolderData) error {
-	configDir, err := os.UserConfigDir()
+	filePath, err := config.Path("feeds.json")
 	if err != nil {
 		return err
 	}
-
-	appDir := filepath.Join(configDir, "core-rss")
-	if err := os.MkdirAll(appDir, 0755); err != nil {
-		return err
-	}
-
-	filePath := filepath.Join(appDir, "feeds.json")
-	file, err := os.Create(filePath)
+	// like the cache, a crash halfway through mustn't leave a broken file
+	tmpPath := filePath + ".tmp"
+	file, err := os.Create(tmpPath)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
 	encoder := json.NewEncoder(file)
 	encoder.SetIndent("", "    ")
-	return encoder.Encode(data)
+	if err := encoder.Encode(data); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, filePath)
 }
 
-func AddFeedToFolder(folder *models.FeedFolder, feedUrl string) (*models.Feed, string, error) {
+// FolderHasFeed reports whether the folder already has a feed with that url
+func FolderHasFeed(folder *models.FeedFolder, feedUrl string) bool {
 	for _, existingFeed := range folder.Feeds {
 		if existingFeed.URL == feedUrl {
-			return nil, "Feed already exists!", fmt.Errorf("feed with URL %s already exists", feedUrl)
+			return true
 		}
 	}
+	return false
+}
 
-	resp, err := http.Get(feedUrl)
+// FetchNewFeed gets and parses a feed before it's added, with auth when it
+// needs credentials (nil otherwise). Pages of well known sites are swapped
+// for their feed first. It's dropped when ctx is cancelled. The message is the
+// one to show to the user
+func FetchNewFeed(ctx context.Context, feedUrl string, auth *models.FeedAuth) (*models.Feed, string, error) {
+	rewritten, err := RewriteFeedURL(ctx, feedUrl)
 	if err != nil {
-		return nil, "Failed to fetch RSS feed", err
+		if ctx.Err() != nil {
+			return nil, "Adding the feed was cancelled", ctx.Err()
+		}
+		return nil, "Failed to find the feed of the page", err
+	}
+	if rewritten == feedUrl {
+		return fetchNewFeed(ctx, feedUrl, auth)
 	}
-	defer resp.Body.Close()
 
-	decoder := xml.NewDecoder(resp.Body)
-	decoder.CharsetReader = func(charsetLabel string, input io.Reader) (io.Reader, error) {
-		return charset.NewReaderLabel(charsetLabel, input)
+	logToFile(fmt.Sprintf("rewrote %s to %s", feedUrl, rewritten))
+	feed, message, err := fetchNewFeed(ctx, rewritten, auth)
+	if err != nil && ctx.Err() == nil {
+		// some rewrites are guesses (any /@user page isn't mastodon), the url
+		// given can still be a feed
+		if original, _, originalErr := fetchNewFeed(ctx, feedUrl, auth); originalErr == nil {
+			return original, "", nil
+		}
 	}
+	return feed, message, err
+}
 
-	var feed models.Feed
-	err = decoder.Decode(&feed)
+func fetchNewFeed(ctx context.Context, feedUrl string, auth *models.FeedAuth) (*models.Feed, string, error) {
+	doc, err := openFeed(ctx, &models.Feed{URL: feedUrl, Auth: auth})
+	if err != nil {
+		var statusErr *StatusError
+		switch {
+		case ctx.Err() != nil:
+			return nil, "Adding the feed was cancelled", ctx.Err()
+		case errors.As(err, &statusErr) && (statusErr.Code == http.StatusUnauthorized || statusErr.Code == http.StatusForbidden):
+			return nil, "The feed needs (other) credentials", err
+		}
+		return nil, "Failed to fetch RSS feed", err
+	}
+	feed, err := readFeed(doc)
 	if err != nil {
+		if ctx.Err() != nil {
+			return nil, "Adding the feed was cancelled", ctx.Err()
+		}
 		logToFile(fmt.Sprintf("error parsing: %v", err))
 		return nil, "Failed to parse RSS feed", err
 	}
 
 	feed.URL = feedUrl
-	folder.Feeds = append(folder.Feeds, &feed)
+	feed.Auth = auth
+	return feed, "", nil
+}
+
+// AddFeedToFolder adds a feed fetched with FetchNewFeed to the folder and
+// saves it
+func AddFeedToFolder(folder *models.FeedFolder, feed *models.Feed) (string, error) {
+	if FolderHasFeed(folder, feed.URL) {
+		return "Feed already exists!", fmt.Errorf("feed with URL %s already exists", feed.URL)
+	}
+
+	folder.Feeds = append(folder.Feeds, feed)
 
 	data, err := LoadFolders()
 	if err != nil {
-		return nil, "Failed to load folders", err
+		return "Failed to load folders", err
 	}
 
 	for i := range data.Folders {
@@ -179,8 +224,75 @@ func AddFeedToFolder(folder *models.FeedFolder, feedUrl string) (*models.Feed, s
 
 	err = SaveFolders(data)
 	if err != nil {
-		return nil, "Failed to save feed", err
+		return "Failed to save feed", err
+	}
+
+	return fmt.Sprintf("Feed %s added successfully!", feed.Title), nil
+}
+
+// ParseFeed decodes an rss or atom document, converting its charset if needed
+func ParseFeed(r io.Reader) (*models.Feed, error) {
+	decoder := xml.NewDecoder(r)
+	decoder.CharsetReader = func(charsetLabel string, input io.Reader) (io.Reader, error) {
+		return charset.NewReaderLabel(charsetLabel, input)
+	}
+
+	// the root element tells rss (<rss>, <rdf:RDF>) and atom (<feed>) apart
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		if start.Name.Local == "feed" {
+			var atom atomFeed
+			if err := decoder.DecodeElement(&atom, &start); err != nil {
+				return nil, err
+			}
+			return atom.toFeed(), nil
+		}
+
+		var feed models.Feed
+		if err := decoder.DecodeElement(&feed, &start); err != nil {
+			return nil, err
+		}
+		return &feed, nil
 	}
+}
 
-	return &feed, fmt.Sprintf("Feed %s added successfully!", feed.Title), nil
+// readFeed parses the document a source got, unless the source built the
+// feed itself
+func readFeed(doc *Document) (*models.Feed, error) {
+	if doc.Feed != nil {
+		return doc.Feed, nil
+	}
+	defer doc.Body.Close()
+	return ParseFeed(doc.Body)
+}
+
+// FetchFeed gets the feed from its source and returns it with its items, the
+// stored feed itself is left untouched. When the feed moved, the returned one
+// has Move set
+func FetchFeed(feed *models.Feed) (*models.Feed, error) {
+	doc, err := openFeed(context.Background(), feed)
+	if err != nil {
+		return nil, err
+	}
+	fetched, err := readFeed(doc)
+	if err != nil {
+		return nil, err
+	}
+	fetched.URL = feed.URL
+	if SourceKind(feed) == "http" {
+		detectMove(fetched, feed.URL, doc.PermanentURL)
+	}
+	for i := range fetched.Items {
+		fetched.Items[i].FeedURL = feed.URL
+		fetched.Items[i].FeedTitle = feed.Title
+	}
+	return fetched, nil
 }
@@ -0,0 +1,165 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/rzinak/core-rss/internal/models"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Source gets the document of a feed from somewhere (the web, a file, a
+// script...), it's parsed the same way whatever the source
+type Source interface {
+	Open(ctx context.Context, feed *models.Feed) (*Document, error)
+}
+
+// Document is what a source got for a feed: a document to parse in Body,
+// or a feed already built in Feed (Body is nil then). PermanentURL is where
+// an http feed got permanently redirected to, if it did
+type Document struct {
+	Body         io.ReadCloser
+	Feed         *models.Feed
+	PermanentURL string
+}
+
+type sourcePrefix struct {
+	prefix string
+	kind   string
+}
+
+var (
+	sources        = map[string]Source{}
+	sourcePrefixes []sourcePrefix
+)
+
+// RegisterSource adds a source backend. Feeds use it when their Source is
+// kind or when their url starts with one of the prefixes
+func RegisterSource(kind string, source Source, prefixes ...string) {
+	sources[kind] = source
+	for _, prefix := range prefixes {
+		sourcePrefixes = append(sourcePrefixes, sourcePrefix{prefix, kind})
+	}
+}
+
+func init() {
+	RegisterSource("http", httpSource{}, "http://", "https://")
+	RegisterSource("file", fileSource{}, "file://")
+	RegisterSource("command", commandSource{}, "exec:")
+	RegisterSource("filter", filterSource{}, "filter:")
+	RegisterSource("scrape", scrapeSource{})
+	RegisterSource("gemini", geminiSource{}, "gemini://")
+	RegisterSource("gopher", gopherSource{}, "gopher://")
+}
+
+// SourceKind is the source a feed is fetched from, set in the feed or
+// guessed from its url
+func SourceKind(feed *models.Feed) string {
+	if feed.Source != "" {
+		return feed.Source
+	}
+	for _, p := range sourcePrefixes {
+		if strings.HasPrefix(strings.ToLower(feed.URL), p.prefix) {
+			return p.kind
+		}
+	}
+	return "http"
+}
+
+func openFeed(ctx context.Context, feed *models.Feed) (*Document, error) {
+	kind := SourceKind(feed)
+	source, ok := sources[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown source %q for %s", kind, feed.URL)
+	}
+	return source.Open(ctx, feed)
+}
+
+type httpSource struct{}
+
+func (httpSource) Open(ctx context.Context, feed *models.Feed) (*Document, error) {
+	resp, permanentUrl, err := getFollowing(ctx, feed.URL, feed.Auth)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, &StatusError{URL: feed.URL, Code: resp.StatusCode, Status: resp.Status}
+	}
+	return &Document{Body: resp.Body, PermanentURL: permanentUrl}, nil
+}
+
+// fileSource reads file:// urls, for feeds generated locally
+type fileSource struct{}
+
+func (fileSource) Open(ctx context.Context, feed *models.Feed) (*Document, error) {
+	parsed, err := url.Parse(feed.URL)
+	if err != nil {
+		return nil, err
+	}
+	filePath := parsed.Path
+	if filePath == "" {
+		// file:relative/path
+		filePath = parsed.Opaque
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return &Document{Body: file}, nil
+}
+
+// scripts get this long to print their feed
+const commandTimeout = 2 * time.Minute
+
+// runCommand runs a shell command and returns what it prints, stdin can be nil
+func runCommand(ctx context.Context, command string, stdin io.Reader) (*Document, error) {
+	ctx, cancel := context.WithTimeout(ctx, commandTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Stdin = stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("running %q: %v: %s", command, err, message)
+		}
+		return nil, fmt.Errorf("running %q: %v", command, err)
+	}
+	return &Document{Body: io.NopCloser(&stdout)}, nil
+}
+
+// commandSource parses what a command prints as the feed, the url is
+// "exec:command" (or just the command, for feeds with source set to command)
+type commandSource struct{}
+
+func (commandSource) Open(ctx context.Context, feed *models.Feed) (*Document, error) {
+	return runCommand(ctx, strings.TrimPrefix(feed.URL, "exec:"), nil)
+}
+
+// filterSource downloads a feed and passes it through a command, the url is
+// "filter:command:url"
+type filterSource struct{}
+
+func (filterSource) Open(ctx context.Context, feed *models.Feed) (*Document, error) {
+	command, feedUrl, ok := strings.Cut(strings.TrimPrefix(feed.URL, "filter:"), ":")
+	if !ok || command == "" || feedUrl == "" {
+		return nil, fmt.Errorf("filter feeds look like filter:command:url, got %s", feed.URL)
+	}
+
+	doc, err := httpSource{}.Open(ctx, &models.Feed{URL: feedUrl, Auth: feed.Auth})
+	if err != nil {
+		return nil, err
+	}
+	defer doc.Body.Close()
+	return runCommand(ctx, command, doc.Body)
+}
@@ -0,0 +1,85 @@
+package services
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/rzinak/core-rss/internal/config"
+	"os"
+	"sync"
+	"time"
+)
+
+// gemini servers mostly use self signed certificates, so they're trusted on
+// first use: the certificate a host shows the first time is pinned in
+// gemini_hosts.json and any other one is refused afterwards, until the
+// pinned one expires
+
+type knownHost struct {
+	Fingerprint string    `json:"fingerprint"` // sha256 of the certificate
+	Expires     time.Time `json:"expires"`
+}
+
+var knownHostsMu sync.Mutex
+
+func loadKnownHosts() (map[string]knownHost, error) {
+	hosts := map[string]knownHost{}
+	filePath, err := config.Path("gemini_hosts.json")
+	if err != nil {
+		return hosts, err
+	}
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return hosts, nil
+		}
+		return hosts, err
+	}
+	if err := json.Unmarshal(data, &hosts); err != nil {
+		return hosts, fmt.Errorf("reading gemini_hosts.json: %v", err)
+	}
+	return hosts, nil
+}
+
+func saveKnownHosts(hosts map[string]knownHost) error {
+	filePath, err := config.Path("gemini_hosts.json")
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(hosts, "", "    ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filePath, data, 0644)
+}
+
+// checkHostCertificate pins the certificate of host (host:port) the first
+// time it's seen and refuses a different one while the pinned one is valid
+func checkHostCertificate(host string, certificate *x509.Certificate) error {
+	sum := sha256.Sum256(certificate.Raw)
+	fingerprint := hex.EncodeToString(sum[:])
+
+	knownHostsMu.Lock()
+	defer knownHostsMu.Unlock()
+
+	hosts, err := loadKnownHosts()
+	if err != nil {
+		// a broken file would otherwise let every certificate in
+		return err
+	}
+	known, ok := hosts[host]
+	if ok && known.Fingerprint == fingerprint {
+		return nil
+	}
+	if ok && time.Now().Before(known.Expires) {
+		return fmt.Errorf("the certificate of %s changed (it's now %s), if that's expected remove %s from gemini_hosts.json", host, fingerprint, host)
+	}
+
+	if ok {
+		logToFile(fmt.Sprintf("the certificate of %s expired, pinning the new one %s", host, fingerprint))
+	}
+	hosts[host] = knownHost{Fingerprint: fingerprint, Expires: certificate.NotAfter}
+	return saveKnownHosts(hosts)
+}
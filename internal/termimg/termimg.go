@@ -0,0 +1,197 @@
+package termimg
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// Protocol is a way of drawing images in the terminal
+type Protocol string
+
+const (
+	None  Protocol = "none"
+	Kitty Protocol = "kitty"
+	Sixel Protocol = "sixel"
+)
+
+// Detect guesses which graphics protocol the terminal understands from the
+// environment, setting is the imageProtocol from the config ("auto" to guess)
+func Detect(setting string) Protocol {
+	switch Protocol(strings.ToLower(setting)) {
+	case Kitty:
+		return Kitty
+	case Sixel:
+		return Sixel
+	case None:
+		return None
+	}
+
+	term := os.Getenv("TERM")
+	program := os.Getenv("TERM_PROGRAM")
+	switch {
+	case os.Getenv("KITTY_WINDOW_ID") != "", term == "xterm-kitty", term == "xterm-ghostty", program == "WezTerm", program == "ghostty":
+		return Kitty
+	case strings.HasPrefix(term, "foot"), strings.HasPrefix(term, "mlterm"), strings.Contains(term, "sixel"), program == "iTerm.app", os.Getenv("WT_SESSION") != "":
+		return Sixel
+	}
+	return None
+}
+
+// Fetch downloads and decodes an image, png, jpeg and gif are supported
+func Fetch(src string) (image.Image, error) {
+	resp, err := http.Get(src)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status fetching %s: %s", src, resp.Status)
+	}
+
+	img, _, err := image.Decode(resp.Body)
+	return img, err
+}
+
+// Draw writes the escape sequences that show img at the cursor position.
+// maxWidth is in pixels, bigger images are scaled down
+func Draw(w io.Writer, img image.Image, protocol Protocol, maxWidth int) error {
+	img = fit(img, maxWidth)
+	switch protocol {
+	case Kitty:
+		return drawKitty(w, img)
+	case Sixel:
+		return drawSixel(w, img)
+	}
+	return fmt.Errorf("the terminal can't show images")
+}
+
+// fit scales the image down (nearest neighbor, it's good enough for a preview)
+func fit(img image.Image, maxWidth int) image.Image {
+	bounds := img.Bounds()
+	if maxWidth <= 0 || bounds.Dx() <= maxWidth {
+		return img
+	}
+
+	height := bounds.Dy() * maxWidth / bounds.Dx()
+	scaled := image.NewRGBA(image.Rect(0, 0, maxWidth, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < maxWidth; x++ {
+			scaled.Set(x, y, img.At(bounds.Min.X+x*bounds.Dx()/maxWidth, bounds.Min.Y+y*bounds.Dy()/height))
+		}
+	}
+	return scaled
+}
+
+// drawKitty sends the image as png, in chunks of 4096 bytes like the kitty
+// graphics protocol asks
+func drawKitty(w io.Writer, img image.Image) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+	encoded := base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	for first := true; len(encoded) > 0; first = false {
+		chunk := encoded
+		if len(chunk) > 4096 {
+			chunk = chunk[:4096]
+		}
+		encoded = encoded[len(chunk):]
+
+		more := 0
+		if len(encoded) > 0 {
+			more = 1
+		}
+		control := fmt.Sprintf("m=%d", more)
+		if first {
+			control = "a=T,f=100," + control
+		}
+		if _, err := fmt.Fprintf(w, "\x1b_G%s;%s\x1b\\", control, chunk); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// drawSixel reduces the image to a 256 color palette and encodes it as
+// sixels: bands of 6 pixel rows, one pass per color
+func drawSixel(w io.Writer, img image.Image) error {
+	bounds := img.Bounds()
+	paletted := image.NewPaletted(image.Rect(0, 0, bounds.Dx(), bounds.Dy()), palette.Plan9)
+	draw.FloydSteinberg.Draw(paletted, paletted.Bounds(), img, bounds.Min)
+
+	var out strings.Builder
+	out.WriteString("\x1bPq")
+	fmt.Fprintf(&out, "\"1;1;%d;%d", paletted.Rect.Dx(), paletted.Rect.Dy())
+	for i, c := range paletted.Palette {
+		r, g, b, _ := color.RGBAModel.Convert(c).RGBA()
+		fmt.Fprintf(&out, "#%d;2;%d;%d;%d", i, r*100/0xffff, g*100/0xffff, b*100/0xffff)
+	}
+
+	width, height := paletted.Rect.Dx(), paletted.Rect.Dy()
+	for top := 0; top < height; top += 6 {
+		used := map[uint8]bool{}
+		for y := top; y < top+6 && y < height; y++ {
+			for x := 0; x < width; x++ {
+				used[paletted.ColorIndexAt(x, y)] = true
+			}
+		}
+
+		firstColor := true
+		for index := range used {
+			if !firstColor {
+				out.WriteByte('$') // back to the start of the band for the next color
+			}
+			firstColor = false
+			fmt.Fprintf(&out, "#%d", index)
+
+			var run byte
+			count := 0
+			flushRun := func() {
+				if count == 0 {
+					return
+				}
+				if count > 3 {
+					fmt.Fprintf(&out, "!%d%c", count, run)
+				} else {
+					out.WriteString(strings.Repeat(string(run), count))
+				}
+			}
+			for x := 0; x < width; x++ {
+				var bits byte
+				for bit := 0; bit < 6 && top+bit < height; bit++ {
+					if paletted.ColorIndexAt(x, top+bit) == index {
+						bits |= 1 << bit
+					}
+				}
+				char := 63 + bits
+				if char == run {
+					count++
+					continue
+				}
+				flushRun()
+				run, count = char, 1
+			}
+			flushRun()
+		}
+		out.WriteByte('-')
+	}
+	out.WriteString("\x1b\\\n")
+
+	_, err := io.WriteString(w, out.String())
+	return err
+}
@@ -0,0 +1,44 @@
+package ui
+
+import (
+	"fmt"
+	"github.com/rzinak/core-rss/internal/models"
+	"github.com/rzinak/core-rss/pkg/utils"
+	"strconv"
+	"strings"
+)
+
+// enclosureInfo describes a media file like "audio/mpeg, 45.3 MB, 1:02:03"
+func enclosureInfo(enclosure models.Enclosure, duration string, downloaded bool) string {
+	parts := []string{}
+	if enclosure.Type != "" {
+		parts = append(parts, enclosure.Type)
+	}
+	if size, err := strconv.ParseInt(strings.TrimSpace(enclosure.Length), 10, 64); err == nil && size > 0 {
+		parts = append(parts, utils.FormatSize(size))
+	}
+	if duration != "" {
+		parts = append(parts, formatDuration(duration))
+	}
+	if downloaded {
+		parts = append(parts, "downloaded")
+	}
+	if len(parts) == 0 {
+		return enclosure.URL
+	}
+	return strings.Join(parts, ", ")
+}
+
+// formatDuration normalizes itunes:duration, which can be plain seconds or
+// [hh:]mm:ss
+func formatDuration(duration string) string {
+	duration = strings.TrimSpace(duration)
+	seconds, err := strconv.Atoi(duration)
+	if err != nil {
+		return duration
+	}
+	if seconds >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", seconds/3600, seconds%3600/60, seconds%60)
+	}
+	return fmt.Sprintf("%d:%02d", seconds/60, seconds%60)
+}
@@ -0,0 +1,75 @@
+package ui
+
+import (
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/rzinak/core-rss/internal/models"
+	"time"
+)
+
+// after this many failed fetches in a row a feed is shown dimmed, it's
+// probably dead
+const staleFailures = 3
+
+var spinnerFrames = []rune("⠋⠙⠹⠸⠼⠴⠦⠧⠇⠏")
+
+// feedLabel is the text of a feed in the tree: a spinner while it's being
+// fetched, a mark when the last fetch failed (or when it's dead) and how long
+// ago it was updated
+func feedLabel(feed *models.Feed, loading bool, frame int, now time.Time) string {
+	label := feed.Title
+	status := feed.Status
+	if status != nil && status.Failures > 0 {
+		label = "! " + label
+	}
+	if status != nil && status.Dead {
+		label += " (dead)"
+	}
+	if loading {
+		return fmt.Sprintf("%s %c", label, spinnerFrames[frame%len(spinnerFrames)])
+	}
+	if status != nil && !status.LastSuccess.IsZero() {
+		label = fmt.Sprintf("%s (updated %s)", label, timeAgo(now.Sub(status.LastSuccess)))
+	}
+	return label
+}
+
+func feedColor(feed *models.Feed) tcell.Color {
+	switch {
+	case feed.Status == nil:
+		return tcell.ColorGreen
+	case feed.Status.Dead:
+		return tcell.ColorMaroon
+	case feed.Status.Failures == 0:
+		return tcell.ColorGreen
+	case feed.Status.Failures >= staleFailures:
+		return tcell.ColorGray
+	}
+	return tcell.ColorRed
+}
+
+func timeAgo(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%d min ago", int(d.Minutes()))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%d h ago", int(d.Hours()))
+	}
+	return fmt.Sprintf("%d days ago", int(d.Hours()/24))
+}
+
+// sameNodes reports whether a and b have the same nodes in the same order
+func sameNodes(a, b []*tview.TreeNode) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,95 @@
+package ui
+
+import (
+	"fmt"
+	"github.com/rzinak/core-rss/internal/models"
+	"sort"
+	"strings"
+	"time"
+)
+
+// healthRow is a line of the feed health dashboard
+type healthRow struct {
+	feed    *models.Feed
+	folder  string
+	perWeek float64
+}
+
+var healthColumns = []string{"Feed", "Folder", "Last success", "Status", "Failures", "Avg response", "Items/week", "Last new item"}
+
+func (r healthRow) status() models.FeedStatus {
+	if r.feed.Status == nil {
+		return models.FeedStatus{}
+	}
+	return *r.feed.Status
+}
+
+func (r healthRow) cells(now time.Time) []string {
+	status := r.status()
+
+	lastStatus := "-"
+	switch {
+	case status.LastFetch.IsZero():
+	case status.LastStatus == 0:
+		lastStatus = "no answer"
+	default:
+		lastStatus = fmt.Sprint(status.LastStatus)
+	}
+	if status.MovedTo != "" {
+		lastStatus += " (moved)"
+	}
+	if status.Dead {
+		lastStatus += " (dead)"
+	}
+
+	avg := "-"
+	if status.AvgResponseMs > 0 {
+		avg = fmt.Sprintf("%d ms", status.AvgResponseMs)
+	}
+
+	perWeek := "-"
+	if r.perWeek > 0 {
+		perWeek = fmt.Sprintf("%.1f", r.perWeek)
+	}
+
+	return []string{r.feed.Title, r.folder, dateAgo(status.LastSuccess, now), lastStatus, fmt.Sprint(status.Failures), avg, perWeek, dateAgo(status.LastNewItem, now)}
+}
+
+func dateAgo(t time.Time, now time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return timeAgo(now.Sub(t))
+}
+
+// sortHealthRows sorts by one of healthColumns, times sort from the most
+// recent and never comes last
+func sortHealthRows(rows []healthRow, column int, reverse bool) {
+	less := func(a, b healthRow) bool {
+		sa, sb := a.status(), b.status()
+		switch column {
+		case 1:
+			return strings.ToLower(a.folder) < strings.ToLower(b.folder)
+		case 2:
+			return sa.LastSuccess.After(sb.LastSuccess)
+		case 3:
+			return sa.LastStatus < sb.LastStatus
+		case 4:
+			return sa.Failures < sb.Failures
+		case 5:
+			return sa.AvgResponseMs < sb.AvgResponseMs
+		case 6:
+			return a.perWeek < b.perWeek
+		case 7:
+			return sa.LastNewItem.After(sb.LastNewItem)
+		}
+		return strings.ToLower(a.feed.Title) < strings.ToLower(b.feed.Title)
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		if reverse {
+			return less(rows[j], rows[i])
+		}
+		return less(rows[i], rows[j])
+	})
+}
@@ -0,0 +1,473 @@
+package ui
+
+import (
+	"fmt"
+	"github.com/mattn/go-runewidth"
+	"github.com/rivo/tview"
+	"golang.org/x/net/html"
+	"net/url"
+	"strings"
+	"unicode"
+)
+
+// the content view doesn't wrap by itself, the renderer wraps paragraphs to
+// the view width so <pre> blocks can be left alone (scroll them with h/l)
+
+const (
+	preStyle     = "[#d7d7af:#262626:-]"
+	gutterStyle  = "[gray::-]"
+	headingColor = "yellow"
+	linkColor    = "#5f87ff"
+	codeColor    = "#d7d7af"
+)
+
+// inlineStyle is the state of the style tags we're inside of
+type inlineStyle struct {
+	color                   string
+	bold, italic, underline bool
+}
+
+func (s inlineStyle) tag() string {
+	color := s.color
+	if color == "" {
+		color = "-"
+	}
+	attrs := ""
+	if s.bold {
+		attrs += "b"
+	}
+	if s.italic {
+		attrs += "i"
+	}
+	if s.underline {
+		attrs += "u"
+	}
+	if attrs == "" {
+		attrs = "-"
+	}
+	return fmt.Sprintf("[%s:-:%s]", color, attrs)
+}
+
+// word is a piece of a paragraph that can't be broken, space says if there
+// was whitespace before it in the html
+type word struct {
+	text  string
+	style inlineStyle
+	space bool
+	br    bool // forced line break (<br>)
+}
+
+type gutter struct {
+	text   string
+	styled string
+}
+
+type articleRenderer struct {
+	width int
+	lines []string
+
+	words        []word
+	pendingSpace bool
+	style        inlineStyle
+	gutters      []gutter
+	bullet       string // replaces the last gutter on the first line of a list item
+	lists        []int  // item counter of each open list, -1 for unordered ones
+
+	base        *url.URL
+	links       []string
+	linkNumbers map[string]int
+	images      map[int]bool
+}
+
+// renderedArticle is an article ready to be shown, links holds the targets
+// of the numbered references ([1] is links[0])
+type renderedArticle struct {
+	text   string
+	links  []string
+	images map[int]bool // link numbers that point to images
+}
+
+// renderHTML converts the html of an article into tview tagged text, wrapped
+// at width columns. Relative links are resolved against base
+func renderHTML(body string, width int, base string) renderedArticle {
+	if width < 20 {
+		width = 20
+	}
+
+	r := &articleRenderer{width: width, linkNumbers: map[string]int{}, images: map[int]bool{}}
+	if parsed, err := url.Parse(base); err == nil && parsed.IsAbs() {
+		r.base = parsed
+	}
+
+	doc, err := html.Parse(strings.NewReader(body))
+	if err != nil {
+		return renderedArticle{text: tview.Escape(body)}
+	}
+	r.walk(doc)
+	r.flush()
+
+	if len(r.links) > 0 {
+		r.blank()
+		r.emit(fmt.Sprintf("[%s:-:b]Links:[-:-:-]", headingColor))
+		for i, link := range r.links {
+			kind := ""
+			if r.images[i+1] {
+				kind = " (image)"
+			}
+			r.emit(fmt.Sprintf("%s%s[-:-:-] %s%s", gutterStyle, tview.Escape(fmt.Sprintf("[%d]", i+1)), tview.Escape(link), kind))
+		}
+	}
+
+	// no need for blank lines at the end
+	for len(r.lines) > 0 && r.lines[len(r.lines)-1] == "" {
+		r.lines = r.lines[:len(r.lines)-1]
+	}
+	return renderedArticle{text: strings.Join(r.lines, "\n"), links: r.links, images: r.images}
+}
+
+// addLink numbers the link target, the same target always gets the same number.
+// Feed sources that run commands (exec:, filter:) aren't links
+func (r *articleRenderer) addLink(href string) int {
+	href = strings.TrimSpace(href)
+	lower := strings.ToLower(href)
+	if href == "" || strings.HasPrefix(href, "#") {
+		return 0
+	}
+	for _, scheme := range []string{"javascript:", "data:", "exec:", "filter:"} {
+		if strings.HasPrefix(lower, scheme) {
+			return 0
+		}
+	}
+	if r.base != nil {
+		if parsed, err := url.Parse(href); err == nil {
+			href = r.base.ResolveReference(parsed).String()
+		}
+	}
+
+	if number, ok := r.linkNumbers[href]; ok {
+		return number
+	}
+	r.links = append(r.links, href)
+	r.linkNumbers[href] = len(r.links)
+	return len(r.links)
+}
+
+func attr(n *html.Node, name string) string {
+	for _, a := range n.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func (r *articleRenderer) prefix(first bool) (raw string, styled string) {
+	var rawB, styledB strings.Builder
+	for i, g := range r.gutters {
+		if first && r.bullet != "" && i == len(r.gutters)-1 {
+			rawB.WriteString(r.bullet)
+			styledB.WriteString(gutterStyle + tview.Escape(r.bullet))
+			continue
+		}
+		rawB.WriteString(g.text)
+		styledB.WriteString(g.styled)
+	}
+	return rawB.String(), styledB.String()
+}
+
+// blank adds an empty line between blocks, never more than one
+func (r *articleRenderer) blank() {
+	if len(r.lines) > 0 && r.lines[len(r.lines)-1] != "" {
+		r.lines = append(r.lines, "")
+	}
+}
+
+func (r *articleRenderer) emit(line string) {
+	r.lines = append(r.lines, line)
+}
+
+// flush wraps the words collected so far into lines
+func (r *articleRenderer) flush() {
+	r.pendingSpace = false
+	if len(r.words) == 0 {
+		return
+	}
+	words := r.words
+	r.words = nil
+
+	first := true
+	var line strings.Builder
+	lineWidth := 0
+	rawPrefix, styledPrefix := r.prefix(true)
+	available := r.width - runewidth.StringWidth(rawPrefix)
+	if available < 10 {
+		available = 10
+	}
+	current := inlineStyle{}
+
+	endLine := func() {
+		r.emit(styledPrefix + "[-:-:-]" + line.String() + "[-:-:-]")
+		line.Reset()
+		lineWidth = 0
+		current = inlineStyle{}
+		if first {
+			first = false
+			r.bullet = ""
+			rawPrefix, styledPrefix = r.prefix(false)
+			available = r.width - runewidth.StringWidth(rawPrefix)
+			if available < 10 {
+				available = 10
+			}
+		}
+	}
+
+	write := func(text string, style inlineStyle) {
+		if style != current {
+			line.WriteString(style.tag())
+			current = style
+		}
+		line.WriteString(tview.Escape(text))
+		lineWidth += runewidth.StringWidth(text)
+	}
+
+	for _, w := range words {
+		if w.br {
+			endLine()
+			continue
+		}
+
+		textWidth := runewidth.StringWidth(w.text)
+		needsSpace := w.space && lineWidth > 0
+		extra := 0
+		if needsSpace {
+			extra = 1
+		}
+
+		if lineWidth > 0 && lineWidth+extra+textWidth > available {
+			endLine()
+			needsSpace = false
+		}
+		if needsSpace {
+			// spaces between differently styled words are left plain, so
+			// underlines don't leak
+			if w.style == current {
+				write(" ", current)
+			} else {
+				write(" ", inlineStyle{})
+			}
+		}
+
+		// words longer than a line (urls, mostly) have to be split
+		text := w.text
+		for runewidth.StringWidth(text) > available-lineWidth && available-lineWidth > 0 {
+			cut := runewidth.Truncate(text, available-lineWidth, "")
+			if cut == "" {
+				break
+			}
+			write(cut, w.style)
+			endLine()
+			text = text[len(cut):]
+		}
+		write(text, w.style)
+	}
+	if lineWidth > 0 {
+		endLine()
+	}
+	r.bullet = ""
+}
+
+// addText splits the text in words, collapsing whitespace like a browser would
+func (r *articleRenderer) addText(text string) {
+	if text == "" {
+		return
+	}
+	if unicode.IsSpace([]rune(text)[0]) {
+		r.pendingSpace = true
+	}
+	for _, field := range strings.Fields(text) {
+		r.words = append(r.words, word{text: field, style: r.style, space: r.pendingSpace})
+		r.pendingSpace = true
+	}
+	runes := []rune(text)
+	r.pendingSpace = unicode.IsSpace(runes[len(runes)-1])
+}
+
+func (r *articleRenderer) withStyle(change func(s *inlineStyle), n *html.Node) {
+	saved := r.style
+	change(&r.style)
+	r.walkChildren(n)
+	r.style = saved
+}
+
+func (r *articleRenderer) walkChildren(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		r.walk(c)
+	}
+}
+
+// preText returns the text of a <pre> as it is, whitespace included
+func preText(n *html.Node) string {
+	var b strings.Builder
+	var collect func(*html.Node)
+	collect = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			b.WriteString(n.Data)
+		case html.ElementNode:
+			if n.Data == "br" {
+				b.WriteString("\n")
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			collect(c)
+		}
+	}
+	collect(n)
+	return b.String()
+}
+
+func (r *articleRenderer) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		r.addText(n.Data)
+		return
+	case html.DocumentNode:
+		r.walkChildren(n)
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	switch n.Data {
+	case "script", "style", "head", "noscript", "template", "iframe":
+		return
+	case "b", "strong":
+		r.withStyle(func(s *inlineStyle) { s.bold = true }, n)
+	case "i", "em", "cite":
+		r.withStyle(func(s *inlineStyle) { s.italic = true }, n)
+	case "u", "ins":
+		r.withStyle(func(s *inlineStyle) { s.underline = true }, n)
+	case "code", "kbd", "samp", "tt":
+		r.withStyle(func(s *inlineStyle) { s.color = codeColor }, n)
+	case "a":
+		r.withStyle(func(s *inlineStyle) {
+			s.color = linkColor
+			s.underline = true
+		}, n)
+		if number := r.addLink(attr(n, "href")); number > 0 {
+			// the reference sticks to the link text
+			r.words = append(r.words, word{text: fmt.Sprintf("[%d]", number), style: inlineStyle{color: "gray"}})
+		}
+	case "img":
+		// images become a placeholder with their alt text, the number lets
+		// you open them (or view them, if the terminal can show images)
+		placeholder := inlineStyle{color: "gray", italic: true}
+		alt := strings.Fields(attr(n, "alt"))
+		if len(alt) == 0 {
+			alt = []string{"image"}
+		}
+		r.words = append(r.words, word{text: "[img:", style: placeholder, space: r.pendingSpace})
+		for _, field := range alt {
+			r.words = append(r.words, word{text: field, style: placeholder, space: true})
+		}
+		r.words = append(r.words, word{text: "]", style: placeholder})
+		if number := r.addLink(attr(n, "src")); number > 0 {
+			r.images[number] = true
+			r.words = append(r.words, word{text: fmt.Sprintf("[%d]", number), style: inlineStyle{color: "gray"}})
+		}
+		r.pendingSpace = true
+	case "br":
+		r.words = append(r.words, word{br: true})
+		r.pendingSpace = false
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		r.flush()
+		r.blank()
+		r.withStyle(func(s *inlineStyle) {
+			s.color = headingColor
+			s.bold = true
+			switch n.Data {
+			case "h1":
+				s.underline = true
+			case "h2":
+			default:
+				s.italic = true
+			}
+		}, n)
+		r.flush()
+		r.blank()
+	case "p", "div", "section", "article", "header", "footer", "figure", "figcaption", "dl", "dt", "dd", "table", "main", "aside", "details", "summary":
+		r.flush()
+		r.walkChildren(n)
+		r.flush()
+		if n.Data == "p" || n.Data == "figure" || n.Data == "table" {
+			r.blank()
+		}
+	case "tr":
+		r.flush()
+		r.walkChildren(n)
+		r.flush()
+	case "td", "th":
+		if len(r.words) > 0 {
+			r.words = append(r.words, word{text: "|", space: true})
+			r.pendingSpace = true
+		}
+		if n.Data == "th" {
+			r.withStyle(func(s *inlineStyle) { s.bold = true }, n)
+		} else {
+			r.walkChildren(n)
+		}
+	case "hr":
+		r.flush()
+		r.blank()
+		width := r.width - 2
+		r.emit(gutterStyle + strings.Repeat("─", width) + "[-:-:-]")
+		r.blank()
+	case "pre":
+		r.flush()
+		r.blank()
+		_, styledPrefix := r.prefix(false)
+		text := strings.ReplaceAll(preText(n), "\t", "    ")
+		text = strings.TrimSuffix(strings.TrimPrefix(text, "\n"), "\n")
+		for _, line := range strings.Split(text, "\n") {
+			r.emit(styledPrefix + preStyle + tview.Escape(line) + " [-:-:-]")
+		}
+		r.blank()
+	case "blockquote":
+		r.flush()
+		r.blank()
+		r.gutters = append(r.gutters, gutter{text: "│ ", styled: gutterStyle + "│ "})
+		r.walkChildren(n)
+		r.flush()
+		r.gutters = r.gutters[:len(r.gutters)-1]
+		r.blank()
+	case "ul", "ol":
+		r.flush()
+		counter := -1
+		if n.Data == "ol" {
+			counter = 0
+		}
+		r.lists = append(r.lists, counter)
+		r.walkChildren(n)
+		r.flush()
+		r.lists = r.lists[:len(r.lists)-1]
+		if len(r.lists) == 0 {
+			r.blank()
+		}
+	case "li":
+		r.flush()
+		bullet := "• "
+		if len(r.lists) > 0 && r.lists[len(r.lists)-1] >= 0 {
+			r.lists[len(r.lists)-1]++
+			bullet = fmt.Sprintf("%d. ", r.lists[len(r.lists)-1])
+		}
+		indent := strings.Repeat(" ", runewidth.StringWidth(bullet))
+		r.gutters = append(r.gutters, gutter{text: indent, styled: indent})
+		r.bullet = bullet
+		r.walkChildren(n)
+		r.flush()
+		r.gutters = r.gutters[:len(r.gutters)-1]
+	default:
+		r.walkChildren(n)
+	}
+}
@@ -0,0 +1,52 @@
+package ui
+
+import (
+	"fmt"
+	"github.com/rivo/tview"
+	"github.com/rzinak/core-rss/internal/models"
+)
+
+// itemLabel is the text of an item in the tree, river views also show which
+// feed the item came from. Titles are escaped, "[PATCH]" isn't a style tag
+func itemLabel(item models.Item, withFeed bool) string {
+	label := tview.Escape(item.Title)
+	if withFeed {
+		label = fmt.Sprintf("%s | %s", tview.Escape(item.FeedTitle), tview.Escape(item.Title))
+		if len(item.Duplicates) > 0 {
+			label = fmt.Sprintf("%s (+%d) | %s", tview.Escape(item.FeedTitle), len(item.Duplicates), tview.Escape(item.Title))
+		}
+	}
+	if item.Starred {
+		label = "* " + label
+	}
+	return label
+}
+
+// riverView is a virtual node in the tree, it doesn't map to a feed but merges
+// the items of several feeds in a single list
+type riverView struct {
+	name    string
+	folder  *models.FeedFolder // nil means every folder
+	keep    func(models.Item) bool
+	starred bool // starred items come straight from the cache, nothing to refresh
+}
+
+// label is computed so folder views follow renames
+func (r *riverView) label() string {
+	if r.folder != nil {
+		return "All items in " + r.folder.Name
+	}
+	return r.name
+}
+
+func (r *riverView) feeds(folderData *models.FolderData) []*models.Feed {
+	if r.folder != nil {
+		return r.folder.Feeds
+	}
+
+	var feeds []*models.Feed
+	for _, folder := range folderData.Folders {
+		feeds = append(feeds, folder.Feeds...)
+	}
+	return feeds
+}
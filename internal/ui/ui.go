@@ -1,21 +1,23 @@
 package ui
 
 import (
-	"bytes"
-	"encoding/xml"
+	"bufio"
+	"context"
 	"fmt"
 	"github.com/gdamore/tcell/v2"
-	"github.com/jaytaylor/html2text"
 	"github.com/rivo/tview"
+	"github.com/rzinak/core-rss/internal/clipboard"
+	"github.com/rzinak/core-rss/internal/config"
 	"github.com/rzinak/core-rss/internal/models"
+	"github.com/rzinak/core-rss/internal/notify"
+	"github.com/rzinak/core-rss/internal/opener"
 	"github.com/rzinak/core-rss/internal/services"
-	// "github.com/rzinak/core-rss/pkg/utils"
-	"golang.org/x/net/html/charset"
+	"github.com/rzinak/core-rss/internal/termimg"
+	"github.com/rzinak/core-rss/pkg/utils"
 	"io"
-	"net/http"
 	"net/url"
 	"os"
-	"os/exec"
+	"strings"
 	"time"
 )
 
@@ -33,8 +35,16 @@ func logToFile(message string) {
 	}
 }
 
-func SetupUI(folderData *models.FolderData) *tview.Pages {
-	app := tview.NewApplication()
+// SetupUI runs the app, foldersLoaded is false when feeds.json couldn't be
+// read and folderData is only a stand-in
+func SetupUI(folderData *models.FolderData, cfg *config.Config, foldersLoaded bool) *tview.Pages {
+	// we keep the screen to send the escape sequences that aren't drawn (the
+	// clipboard, notifications) through it
+	screen, err := tcell.NewScreen()
+	if err != nil {
+		panic(err)
+	}
+	app := tview.NewApplication().SetScreen(screen)
 
 	if len(folderData.Folders) == 0 {
 		folderData.Folders = append(folderData.Folders, models.FeedFolder{
@@ -59,13 +69,13 @@ func SetupUI(folderData *models.FolderData) *tview.Pages {
 	contentView.SetDynamicColors(true)
 	contentView.SetBackgroundColor(tcell.Color(tcell.ColorValues[0x000000]))
 	contentView.SetScrollable(true)
-	contentView.SetWrap(true)
+	contentView.SetWrap(false) // articles are wrapped by renderHTML, except for code blocks
 	contentView.SetBorder(true)
 	contentView.SetBorderStyle(tcell.StyleDefault.Foreground(tcell.ColorGreen))
 	contentView.SetTitleColor(tcell.ColorGreen)
 	contentView.SetTitle("Core RSS")
 
-	defaultStatusBarMsg := "?: help | q: quit | Tab: switch focus | j/k: navigate | a: add new feed | d: remove a feed | f: add a folder | r: rename a folder | To see more, press '?'"
+	defaultStatusBarMsg := "?: help | q: quit | Tab: switch focus | j/k: navigate | a: add new feed | d: remove a feed | f: add a folder | r: rename a folder | /: search | F: filter | To see more, press '?'"
 
 	statusBar := tview.NewTextView()
 	statusBar.SetTextAlign(tview.AlignLeft)
@@ -97,7 +107,17 @@ func SetupUI(folderData *models.FolderData) *tview.Pages {
 		Press 'a' to add a new feed
 		Press 'f' to add a folder
 		Press 'r' to rename a folder
-		Press 'Ctrl + O' to open the current post in the browser`)
+		Press '/' to search the cached articles
+		Press 'F' to filter the tree, ESC to clear the filter
+		Open 'All unread', 'Today' or 'All items in <folder>' to see every feed at once
+		Press 's' to star/unstar an item, starred items are kept under 'Starred'
+		Press 'Ctrl + O' to open the current post in the browser
+		Press 'L' in an article to pick one of its links, or type its number and then 'o' (open), 'y' (copy), 'A' (add as feed) or 'i' (view image)
+		Press 'y' to copy the article link, 'Y' to copy it as a markdown link, 'c' to copy the article text
+		Press 'e' in an article to load the full article from its link, 'E' on a feed to always do it
+		Press 'D' in an item to download its media (podcasts) and 'p' to play it
+		Press 'N' on a feed to get notified of its new items
+		Press 'H' to see the health of every feed and retry, fix or remove the broken ones`)
 	helpModal.AddButtons([]string{"Close"})
 	helpModal.SetBorder(true)
 	helpModal.SetBorderStyle(tcell.StyleDefault.Foreground(tcell.ColorGreen))
@@ -120,6 +140,81 @@ func SetupUI(folderData *models.FolderData) *tview.Pages {
 	confirmModal.SetButtonTextColor(tcell.ColorGreen)
 	confirmModal.SetButtonBackgroundColor(tcell.ColorBlack)
 
+	newRiverNode := func(view *riverView) *tview.TreeNode {
+		riverNode := tview.NewTreeNode(view.label()).SetReference(view)
+		riverNode.SetTextStyle(tcell.StyleDefault.Foreground(tcell.ColorYellow).Background(tcell.Color(tcell.ColorValues[0x000000])))
+		riverNode.SetSelectedTextStyle(tcell.StyleDefault.Foreground(tcell.ColorBlack).Background(tcell.ColorYellow))
+		return riverNode
+	}
+
+	folderRiver := func(folder *models.FeedFolder) *riverView {
+		return &riverView{folder: folder}
+	}
+
+	// feeds being fetched show a spinner, loadingFeeds is only touched from
+	// the ui goroutine
+	loadingFeeds := map[*models.Feed]bool{}
+	spinnerFrame := 0
+	spinning := false
+
+	styleFeedNode := func(node *tview.TreeNode, feed *models.Feed) {
+		node.SetText(feedLabel(feed, loadingFeeds[feed], spinnerFrame, time.Now()))
+		node.SetColor(feedColor(feed))
+	}
+
+	newFeedNode := func(feed *models.Feed) *tview.TreeNode {
+		feedNode := tview.NewTreeNode(feed.Title).SetReference(feed)
+		styleFeedNode(feedNode, feed)
+		return feedNode
+	}
+
+	updateFeedNodes := func() {
+		root.Walk(func(node, parent *tview.TreeNode) bool {
+			if feed, ok := node.GetReference().(*models.Feed); ok {
+				styleFeedNode(node, feed)
+			}
+			return true
+		})
+	}
+
+	// setLoading marks the feeds as being fetched (or done), the spinner runs
+	// while there's any feed loading
+	setLoading := func(feeds []*models.Feed, loading bool) {
+		for _, feed := range feeds {
+			if loading {
+				loadingFeeds[feed] = true
+			} else {
+				delete(loadingFeeds, feed)
+			}
+		}
+		updateFeedNodes()
+
+		if !loading || spinning {
+			return
+		}
+		spinning = true
+		go func() {
+			ticker := time.NewTicker(100 * time.Millisecond)
+			defer ticker.Stop()
+			for range ticker.C {
+				keepSpinning := make(chan bool, 1)
+				app.QueueUpdateDraw(func() {
+					spinnerFrame++
+					updateFeedNodes()
+					spinning = len(loadingFeeds) > 0
+					keepSpinning <- spinning
+				})
+				if !<-keepSpinning {
+					return
+				}
+			}
+		}()
+	}
+
+	root.AddChild(newRiverNode(&riverView{name: "All unread", keep: services.IsUnread}))
+	root.AddChild(newRiverNode(&riverView{name: "Today", keep: services.IsToday}))
+	root.AddChild(newRiverNode(&riverView{name: "Starred", starred: true}))
+
 	for i := range folderData.Folders {
 		folder := &folderData.Folders[i]
 		folderNode := tview.NewTreeNode(folder.Name).SetReference(folder)
@@ -129,260 +224,930 @@ func SetupUI(folderData *models.FolderData) *tview.Pages {
 
 		folder.FolderNode = folderNode
 		root.AddChild(folderNode)
+		folderNode.AddChild(newRiverNode(folderRiver(folder)))
 
 		for _, feed := range folder.Feeds {
-			feedNode := tview.NewTreeNode(feed.Title).SetReference(feed)
-			feedNode.SetColor(tcell.ColorGreen)
-			folderNode.AddChild(feedNode)
+			folderNode.AddChild(newFeedNode(feed))
 		}
 	}
 
-	resetStatusBarMsg := func(secondsToDisappear int) {
-		go func() {
-			time.Sleep(time.Duration(secondsToDisappear) * time.Second)
-			app.QueueUpdateDraw(func() {
-				statusBar.SetText(defaultStatusBarMsg)
-			})
-		}()
+	// the tree filter rebuilds the folders from folderData every time it
+	// changes, so feeds added, removed or moved while it's on show up as they
+	// are. filterOpen has the folders that were open before filtering, to
+	// leave them like that once it's cleared, it's nil when there's no filter
+	var filterOpen map[*tview.TreeNode]bool
+	filterQuery := ""
+	// the nodes are reused so expanded feeds stay expanded
+	var filterFeedNodes map[*models.Feed]*tview.TreeNode
+	var filterRivers map[*tview.TreeNode]*tview.TreeNode
+	var topRivers []*tview.TreeNode
+	for _, node := range root.GetChildren() {
+		if _, ok := node.GetReference().(*riverView); ok {
+			topRivers = append(topRivers, node)
+		}
 	}
 
-	var currentItem *models.Item
+	// the items of expanded feeds and rivers are filtered too. filterItems has
+	// all the items of a node to put them back, filterShown the ones left, so
+	// a node reloaded in the meantime is noticed
+	var filterItems, filterShown map[*tview.TreeNode][]*tview.TreeNode
 
-	tree.SetSelectedFunc(func(node *tview.TreeNode) {
-		reference := node.GetReference()
-		switch v := reference.(type) {
+	filterText := func(node *tview.TreeNode) string {
+		switch ref := node.GetReference().(type) {
+		case *models.Feed:
+			// not the "updated N min ago" part
+			return ref.Title
 		case models.Item:
-			currentItem = &v
-			var content string
-			var err error
-			if v.Content != "" {
-				content, err = html2text.FromString(v.Content, html2text.Options{PrettyTables: true})
-			} else {
-				content, err = html2text.FromString(v.Description, html2text.Options{PrettyTables: true})
+			// the label is escaped
+			return ref.FeedTitle + " " + ref.Title
+		}
+		return node.GetText()
+	}
+
+	// filterNode leaves the items of a feed or river node that match, all of
+	// them when the node itself matches or all is set. It reports whether the
+	// node should stay
+	filterNode := func(node *tview.TreeNode, query string, all bool) bool {
+		children := node.GetChildren()
+		if shown, ok := filterShown[node]; !ok || !sameNodes(shown, children) {
+			filterItems[node] = children
+		}
+		all = all || query == "" || utils.FuzzyMatch(filterText(node), query)
+
+		var shown []*tview.TreeNode
+		for _, item := range filterItems[node] {
+			if all || utils.FuzzyMatch(filterText(item), query) {
+				shown = append(shown, item)
 			}
+		}
+		filterShown[node] = shown
+		node.SetChildren(shown)
+		return all || len(shown) > 0
+	}
 
-			if err != nil {
-				content = "error parsing content: " + err.Error()
-				statusBar.SetText(content)
+	// filterTree shows the folders, feeds and items matching the query, a
+	// matching folder keeps all its feeds and a matching feed all its items.
+	// An empty query shows everything again
+	filterTree := func(query string) {
+		filterQuery = query
+		var top []*tview.TreeNode
+		for _, node := range topRivers {
+			if filterNode(node, query, false) {
+				top = append(top, node)
 			}
+		}
 
-			contentView.Clear()
-			fmt.Fprintf(contentView, "[yellow]Published: %s\n\n%s", v.PubDate, content)
-			contentView.SetTitle(v.Title)
-			contentView.SetTitleColor(tcell.ColorYellow)
-			app.SetFocus(contentView)
-		case *models.Feed:
-			if len(node.GetChildren()) > 0 {
-				node.SetChildren(nil)
-			} else {
-				go func() {
-					resp, err := http.Get(v.URL)
-					if err != nil {
-						return
-					}
-					defer resp.Body.Close()
+		for i := range folderData.Folders {
+			folder := &folderData.Folders[i]
+			folderNode := folder.FolderNode
+			if folderNode == nil {
+				continue
+			}
+			folderMatches := query == "" || utils.FuzzyMatch(folder.Name, query)
 
-					body, err := io.ReadAll(resp.Body)
-					resp.Body = io.NopCloser(bytes.NewReader(body))
+			river, ok := filterRivers[folderNode]
+			if !ok {
+				river = newRiverNode(folderRiver(folder))
+				filterRivers[folderNode] = river
+			}
+			var children []*tview.TreeNode
+			if filterNode(river, query, folderMatches) {
+				children = append(children, river)
+			}
+			for _, feed := range folder.Feeds {
+				feedNode, ok := filterFeedNodes[feed]
+				if !ok {
+					feedNode = newFeedNode(feed)
+					filterFeedNodes[feed] = feedNode
+				}
+				if filterNode(feedNode, query, folderMatches) {
+					children = append(children, feedNode)
+				}
+			}
 
-					decoder := xml.NewDecoder(resp.Body)
+			if query == "" && !filterOpen[folderNode] {
+				children = nil
+			} else if len(children) == 0 {
+				continue
+			}
+			folderNode.SetChildren(children)
+			folderNode.SetExpanded(true)
+			top = append(top, folderNode)
+		}
+		root.SetChildren(top)
+	}
 
-					decoder.CharsetReader = func(charsetLabel string, input io.Reader) (io.Reader, error) {
-						logToFile(fmt.Sprintf("charSetLabel: %s", charsetLabel))
-						return charset.NewReaderLabel(charsetLabel, input)
-					}
+	startFilter := func() {
+		filterOpen = map[*tview.TreeNode]bool{}
+		filterFeedNodes = map[*models.Feed]*tview.TreeNode{}
+		filterRivers = map[*tview.TreeNode]*tview.TreeNode{}
+		filterItems = map[*tview.TreeNode][]*tview.TreeNode{}
+		filterShown = map[*tview.TreeNode][]*tview.TreeNode{}
+		for _, folder := range folderData.Folders {
+			if folder.FolderNode == nil {
+				continue
+			}
+			for _, child := range folder.FolderNode.GetChildren() {
+				filterOpen[folder.FolderNode] = true
+				switch ref := child.GetReference().(type) {
+				case *models.Feed:
+					filterFeedNodes[ref] = child
+				case *riverView:
+					filterRivers[folder.FolderNode] = child
+				}
+			}
+		}
+	}
 
-					var feedData models.Feed
-					// logToFile(fmt.Sprintf("feedData: %s", feedData))
+	restoreTree := func() {
+		filterTree("")
+		filterOpen = nil
+		tree.SetTitle("Core RSS")
+	}
 
-					err = decoder.Decode(&feedData)
-					if err != nil {
-						return
-					}
+	// refilter is called after the feeds or folders change
+	refilter := func() {
+		if filterOpen != nil {
+			filterTree(filterQuery)
+		}
+	}
 
-					// logToFile(fmt.Sprintf("&feedData: %s", &feedData))
+	resetStatusBarMsg := func(secondsToDisappear int) {
+		go func() {
+			time.Sleep(time.Duration(secondsToDisappear) * time.Second)
+			app.QueueUpdateDraw(func() {
+				statusBar.SetText(defaultStatusBarMsg)
+			})
+		}()
+	}
 
-					app.QueueUpdateDraw(func() {
-						for _, item := range feedData.Items {
-							itemCopy := item
-							feedItemNode := tview.NewTreeNode(itemCopy.Title).SetReference(itemCopy)
-							feedItemNode.SetTextStyle(tcell.StyleDefault.Foreground(tcell.ColorGreen).Background(tcell.Color(tcell.ColorValues[0x000000])))
-							feedItemNode.SetSelectedTextStyle(tcell.StyleDefault.Foreground(tcell.ColorBlack).Background(tcell.ColorGreen))
-							node.AddChild(feedItemNode)
-						}
-						statusBar.SetText(fmt.Sprintf("loaded %d items for feed: %s", len(feedData.Items), v.Title))
-					})
+	// every item we fetch is cached, the search index is built from it in the
+	// background since converting all the html can take a while
+	itemCache, err := services.LoadCache()
+	if err != nil {
+		logToFile(fmt.Sprintf("error loading cache: %v", err))
+	}
+	var cachedItems []models.Item
+	for _, cached := range itemCache.Feeds {
+		cachedItems = append(cachedItems, cached.Items...)
+	}
+	searchIndex := services.NewSearchIndex()
+	go searchIndex.Add(cachedItems...)
 
-					resetStatusBarMsg(5)
-				}()
-			}
-		case *models.FeedFolder:
-			// handle folder selection (toggle feeds)
-			if len(node.GetChildren()) > 0 {
-				node.SetChildren(nil) // collapse here
-			} else {
-				// expand
-				for _, feed := range v.Feeds {
-					feedNode := tview.NewTreeNode(feed.Title).SetReference(feed)
-					feedNode.SetColor(tcell.ColorGreen)
-					node.AddChild(feedNode)
-				}
-			}
+	saveFolders := func() {
+		if err := services.SaveFolders(folderData); err != nil {
+			logToFile(fmt.Sprintf("error saving feeds: %v", err))
 		}
-	})
+	}
+	// the saves we do on our own (the fetch status of feeds, moved feeds) are
+	// skipped when feeds.json couldn't be read, they would replace the user's
+	// feeds with the empty stand-in
+	autoSaveFolders := func() {
+		if foldersLoaded {
+			saveFolders()
+		}
+	}
 
-	addFeedForm := tview.NewForm()
-	addFeedForm.AddInputField("RSS Feed URL: ", "", 0, nil, nil)
-	addFeedForm.AddButton("Add", func() {
-		url := addFeedForm.GetFormItem(0).(*tview.InputField).GetText()
-		if url != "" {
-			selectedNode := tree.GetCurrentNode()
-			var targetFolder *models.FeedFolder
+	// the retention policies are enforced in the background, on the ui
+	// goroutine since that's the one touching the cache
+	if cfg.CleanupIntervalMinutes > 0 {
+		stopCleanup := services.StartCleanup(time.Duration(cfg.CleanupIntervalMinutes)*time.Minute, func() {
+			app.QueueUpdate(func() {
+				report := services.PurgeCache(itemCache, folderData, cfg.Retention)
+				if len(report.Removed) == 0 {
+					return
+				}
+				go searchIndex.Remove(report.Removed...)
+				if err := services.SaveCache(itemCache); err != nil {
+					logToFile(fmt.Sprintf("error saving cache: %v", err))
+				}
+				logToFile(fmt.Sprintf("cleanup removed %d cached items", len(report.Removed)))
+			})
+		})
+		defer stopCleanup()
+	}
 
-			if selectedNode != nil {
-				ref := selectedNode.GetReference()
-				switch v := ref.(type) {
-				case *models.FeedFolder:
-					targetFolder = v
-				case *models.Feed:
-					// if a feed is selected, gotta search through folderData to find its parent folder
-					for i := range folderData.Folders {
-						folder := &folderData.Folders[i]
-						for _, feed := range folder.Feeds {
-							if feed == v {
-								targetFolder = folder
-								break
-							}
-						}
-						if targetFolder != nil {
-							break
-						}
-					}
+	var currentItem *models.Item
+
+	feedByURL := func(feedUrl string) *models.Feed {
+		for _, folder := range folderData.Folders {
+			for _, feed := range folder.Feeds {
+				if feed.URL == feedUrl {
+					return feed
 				}
 			}
+		}
+		return nil
+	}
 
-			// if no folder is found, gotta use the first folder
-			if targetFolder == nil && len(folderData.Folders) > 0 {
-				targetFolder = &folderData.Folders[0]
+	downloads := services.NewDownloadManager(cfg.DownloadDir, func(d services.Download, queued int) {
+		app.QueueUpdateDraw(func() {
+			name := services.FileName(d.URL)
+			queuedMsg := ""
+			if queued > 0 {
+				queuedMsg = fmt.Sprintf(" (%d more in the queue)", queued)
 			}
 
-			if targetFolder != nil {
-				feed, message, err := services.AddFeedToFolder(targetFolder, url)
-				if err != nil {
-					statusBar.SetText("Error: " + message)
-					resetStatusBarMsg(5)
+			switch d.State {
+			case services.DownloadRunning:
+				if percent := d.Percent(); percent >= 0 {
+					statusBar.SetText(fmt.Sprintf("Downloading %s: %d%% of %s%s", name, percent, utils.FormatSize(d.Total), queuedMsg))
 				} else {
-					// here i add the feed node to the UI
-					if targetFolder.FolderNode != nil {
-						feedNode := tview.NewTreeNode(feed.Title).SetReference(feed)
-						feedNode.SetColor(tcell.ColorGreen)
-						targetFolder.FolderNode.AddChild(feedNode)
-					}
-					services.SaveFolders(folderData)
-					statusBar.SetText(message)
-					resetStatusBarMsg(5)
+					statusBar.SetText(fmt.Sprintf("Downloading %s: %s%s", name, utils.FormatSize(d.Done), queuedMsg))
 				}
-			} else {
-				statusBar.SetText("No folder available to add feed")
+			case services.DownloadDone:
+				statusBar.SetText(fmt.Sprintf("Downloaded %s to %s%s", name, d.Path, queuedMsg))
+				resetStatusBarMsg(5)
+			case services.DownloadFailed:
+				logToFile(fmt.Sprintf("error downloading %s: %v", d.URL, d.Err))
+				statusBar.SetText(fmt.Sprintf("Error downloading %s: %v%s", name, d.Err, queuedMsg))
 				resetStatusBarMsg(5)
 			}
-		}
-		pages.HidePage("addFeed")
+		})
 	})
 
-	addFeedForm.SetButtonsAlign(1)
+	// renderedWidth is the width the current item was rendered for, the
+	// article is rendered again when the content view is resized
+	renderedWidth := 0
+	var currentLinks []string
+	var currentImages map[int]bool
 
-	addFeedForm.SetBackgroundColor(tcell.Color(tcell.ColorValues[0x000000]))
-	addFeedForm.SetBorderStyle(tcell.StyleDefault.Foreground(tcell.ColorGreen))
-	addFeedForm.SetTitleColor(tcell.ColorGreen)
-	addFeedForm.SetFieldBackgroundColor(tcell.Color(tcell.ColorValues[0x000000]))
-	addFeedForm.SetFieldTextColor(tcell.ColorGreen)
-	addFeedForm.SetButtonTextColor(tcell.ColorGreen)
-	addFeedForm.SetButtonBackgroundColor(tcell.ColorBlack)
+	renderItem := func(item models.Item) {
+		_, _, width, _ := contentView.GetInnerRect()
+		if width <= 0 {
+			width = 80
+		}
+		renderedWidth = width
 
-	closingTipText := tview.NewTextView().
-		SetText("Tip: Press 'ESC' to close this window")
+		body := item.FullContent
+		if body == "" {
+			body = item.Content
+		}
+		if body == "" {
+			body = item.Description
+		}
 
-	closingTipText.SetBackgroundColor(tcell.Color(tcell.ColorValues[0x000000]))
-	closingTipText.SetTextAlign(1)
+		contentView.Clear()
+		if others := services.OtherFeeds(item); len(others) > 0 {
+			fmt.Fprintf(contentView, "[yellow]Also in: %s\n", tview.Escape(strings.Join(others, ", ")))
+		}
+		article := renderHTML(body, width-1, item.Link)
+		currentLinks = article.links
+		currentImages = article.images
+		fmt.Fprintf(contentView, "[yellow]Published: %s[-:-:-]\n", tview.Escape(item.PubDate))
+		for _, enclosure := range item.Enclosures {
+			fmt.Fprintf(contentView, "[yellow]Media: %s[-:-:-]\n", tview.Escape(enclosureInfo(enclosure, item.Duration, downloads.Downloaded(enclosure.URL))))
+		}
+		fmt.Fprintf(contentView, "\n%s", article.text)
+	}
 
-	addFeedFormLayout := tview.NewFlex().
-		SetDirection(tview.FlexRow).
-		AddItem(addFeedForm, 0, 1, true).
-		AddItem(closingTipText, 1, 0, false)
+	app.SetBeforeDrawFunc(func(screen tcell.Screen) bool {
+		if currentItem != nil {
+			if _, _, width, _ := contentView.GetInnerRect(); width > 0 && width != renderedWidth {
+				row, col := contentView.GetScrollOffset()
+				renderItem(*currentItem)
+				contentView.ScrollTo(row, col)
+			}
+		}
+		return false
+	})
 
-	addFeedFormLayout.SetBorder(true).
-		SetBorderStyle(tcell.StyleDefault.Foreground(tcell.ColorGreen)).
-		SetTitle("Add a new feed").
-		SetTitleColor(tcell.ColorGreen)
+	// loadFullArticle fetches the page the item links to and shows its main
+	// content instead of the summary that came in the feed
+	var loadFullArticle func(item models.Item)
 
-	// flex container to center the form
-	formFlex.AddItem(nil, 0, 1, false)
-	formFlex.AddItem(tview.NewFlex().
-		AddItem(nil, 0, 1, false).
-		AddItem(addFeedFormLayout, 70, 1, true).
-		AddItem(nil, 0, 1, false), 8, 1, true).
-		AddItem(nil, 0, 1, false)
+	showItem := func(item models.Item) {
+		currentItem = &item
 
-	addFeedForm.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		if event.Key() == tcell.KeyEsc {
-			pages.HidePage("addFeed")
-			app.SetFocus(tree)
-			return nil
+		// reading an article once is enough, no matter which feed it came from
+		markedRead := services.MarkRead(itemCache, item)
+		for _, duplicate := range item.Duplicates {
+			markedRead = services.MarkRead(itemCache, duplicate) || markedRead
+		}
+		if markedRead {
+			if err := services.SaveCache(itemCache); err != nil {
+				logToFile(fmt.Sprintf("error saving cache: %v", err))
+			}
 		}
-		return event
-	})
 
-	addFolderForm := tview.NewForm()
-	addFolderForm.SetFieldBackgroundColor(tcell.Color(tcell.ColorValues[0x000000]))
-	addFolderForm.SetFieldTextColor(tcell.ColorGreen)
-	addFolderForm.AddInputField("Folder Name: ", "", 0, nil, nil)
-	addFolderForm.SetBackgroundColor(tcell.Color(tcell.ColorValues[0x000000]))
-	addFolderForm.AddButton("Add", func() {
-		folderName := addFolderForm.GetFormItem(0).(*tview.InputField).GetText()
-		if folderName != "" {
-			for _, folder := range folderData.Folders {
-				if folder.Name == folderName {
-					statusBar.SetText("Folder already exists!")
+		renderItem(item)
+		contentView.SetTitle(item.Title)
+		contentView.SetTitleColor(tcell.ColorYellow)
+		contentView.ScrollToBeginning()
+		app.SetFocus(contentView)
+
+		if item.FullContent == "" {
+			if feed := feedByURL(item.FeedURL); feed != nil && feed.FullText {
+				loadFullArticle(item)
+			} else if item.Content == "" && item.Description == "" && services.IsSmallWebLink(item.Link) {
+				// gemlog and phlog feeds only have links, the post is the content
+				loadFullArticle(item)
+			}
+		}
+	}
+
+	loadFullArticle = func(item models.Item) {
+		statusBar.SetText("Loading full article...")
+		go func() {
+			content, err := services.FetchFullArticle(item.Link)
+			app.QueueUpdateDraw(func() {
+				if err != nil {
+					logToFile(fmt.Sprintf("error loading full article %s: %v", item.Link, err))
+					statusBar.SetText("Error loading full article: " + err.Error())
 					resetStatusBarMsg(5)
 					return
 				}
-			}
 
-			newFolder := models.FeedFolder{
-				Name:  folderName,
-				Feeds: []*models.Feed{},
-			}
+				if services.SetFullContent(itemCache, item, content) {
+					if err := services.SaveCache(itemCache); err != nil {
+						logToFile(fmt.Sprintf("error saving cache: %v", err))
+					}
+				}
 
-			folderData.Folders = append(folderData.Folders, newFolder)
+				// the user may have moved on to another item in the meantime
+				if currentItem != nil && currentItem.FeedURL == item.FeedURL && services.ItemKey(*currentItem) == services.ItemKey(item) {
+					currentItem.FullContent = content
+					renderItem(*currentItem)
+					contentView.ScrollToBeginning()
+				}
+				statusBar.SetText("Full article loaded")
+				resetStatusBarMsg(5)
+			})
+		}()
+	}
 
-			newFolderNode := tview.NewTreeNode(folderName).SetReference(&newFolder)
-			newFolderNode.SetColor(tcell.ColorGreen)
-			newFolderNode.SetTextStyle(tcell.StyleDefault.Foreground(tcell.ColorGreen).Background(tcell.Color(tcell.ColorValues[0x000000])))
-			newFolderNode.SetSelectedTextStyle(tcell.StyleDefault.Foreground(tcell.ColorBlack).Background(tcell.ColorGreen))
-			root.AddChild(newFolderNode)
+	ruleSet, err := services.NewRuleSet(cfg.Rules)
+	if err != nil {
+		logToFile(err.Error())
+		statusBar.SetText("Error: some rules in config.json are invalid, check log.txt")
+		resetStatusBarMsg(10)
+	}
 
-			services.SaveFolders(folderData)
-			statusBar.SetText(fmt.Sprintf("Folder '%s' created successfully!", folderName))
-			resetStatusBarMsg(5)
+	folderOf := func(feedUrl string) string {
+		for _, folder := range folderData.Folders {
+			for _, feed := range folder.Feeds {
+				if feed.URL == feedUrl {
+					return folder.Name
+				}
+			}
 		}
-		pages.HidePage("addFolder")
-		app.SetFocus(tree)
+		return ""
+	}
+
+	// new items are notified in batches, so a refresh with lots of them
+	// ends up as one "12 new items in Work"
+	notifier := notify.NewAggregator(time.Duration(cfg.Notifications.IntervalSeconds)*time.Second, func(title, body string) error {
+		err := notify.Send(title, body)
+		if err != nil {
+			// without a desktop it goes to the terminal, from the ui
+			// goroutine so it's not written in the middle of a draw
+			app.QueueUpdate(func() {
+				if tty, ok := screen.Tty(); ok {
+					_, err = io.WriteString(tty, notify.TerminalSequence(title, body))
+				}
+			})
+		}
+		if err != nil {
+			logToFile(fmt.Sprintf("error sending notification: %v", err))
+		}
+		return err
 	})
-	addFolderForm.SetButtonsAlign(1)
-	addFolderForm.SetButtonTextColor(tcell.ColorGreen)
-	addFolderForm.SetButtonBackgroundColor(tcell.ColorBlack)
 
-	folderFormTipText := tview.NewTextView()
-	folderFormTipText.SetText("Tip: Press 'ESC' to close")
-	folderFormTipText.SetTextAlign(1)
-	folderFormTipText.SetBackgroundColor(tcell.Color(tcell.ColorValues[0x000000]))
+	wantsNotification := func(feed *models.Feed, folder string, outcome services.RuleOutcome) bool {
+		if outcome.Hide {
+			return false
+		}
+		if cfg.Notifications.All || feed.Notify || outcome.Notify {
+			return true
+		}
+		for _, name := range cfg.Notifications.Folders {
+			if name == folder {
+				return true
+			}
+		}
+		return false
+	}
 
-	folderFormLayout := tview.NewFlex().
-		SetDirection(tview.FlexRow).
-		AddItem(addFolderForm, 5, 1, true).
-		AddItem(folderFormTipText, 1, 0, false)
-	folderFormLayout.SetBorder(true).
+	// cacheFetched runs the rules on freshly fetched items and caches them,
+	// must be called from the ui goroutine. It returns how many items are new
+	cacheFetched := func(feed *models.Feed, items []models.Item) int {
+		folder := folderOf(feed.URL)
+		ruleSet.ApplyOnFetch(items, folder)
+		// the first fetch of a feed brings everything, that's not news
+		_, seenBefore := itemCache.Feeds[feed.URL]
+		newItems := services.CacheItems(itemCache, feed, items)
+		go searchIndex.Add(items...)
+
+		if !seenBefore {
+			return len(newItems)
+		}
+		for _, item := range newItems {
+			if !item.Read && wantsNotification(feed, folder, ruleSet.Match(item, folder)) {
+				notifier.Add(folder, item.Title)
+			}
+		}
+		return len(newItems)
+	}
+
+	// moveFeed changes the url of a feed, its items are indexed under the url
+	// so they're taken out of the search index and put back under the new one
+	moveFeed := func(feed *models.Feed, newUrl string) {
+		logToFile(fmt.Sprintf("updating the url of '%s' from %s to %s", feed.Title, feed.URL, newUrl))
+		if cached, ok := itemCache.Feeds[feed.URL]; ok {
+			searchIndex.Remove(cached.Items...)
+		}
+		services.ChangeFeedURL(itemCache, feed, newUrl)
+		if cached, ok := itemCache.Feeds[feed.URL]; ok {
+			go searchIndex.Add(append([]models.Item(nil), cached.Items...)...)
+		}
+		if feed.Status != nil {
+			feed.Status.MovedTo = ""
+		}
+	}
+
+	// applyMove follows a feed that moved: permanent redirects within the same
+	// site are applied right away with updateMovedFeeds set to auto, other
+	// moves wait for the user to accept them in the health dashboard. So do
+	// the moves of feeds with credentials, those would go along with the url.
+	// Must run before the items are cached, so they're cached under the new url
+	applyMove := func(feed *models.Feed, fetched *models.Feed) {
+		if feed.Status == nil {
+			feed.Status = &models.FeedStatus{}
+		}
+		move := fetched.Move
+		if move == nil || cfg.UpdateMovedFeeds == "never" {
+			feed.Status.MovedTo = ""
+			return
+		}
+
+		if move.Permanent && cfg.UpdateMovedFeeds == "auto" && feed.Auth == nil && services.SameSite(feed.URL, move.URL) {
+			moveFeed(feed, move.URL)
+			statusBar.SetText(fmt.Sprintf("'%s' moved, its url was updated to %s", feed.Title, move.URL))
+			resetStatusBarMsg(10)
+			return
+		}
+
+		if feed.Status.MovedTo != move.URL {
+			statusBar.SetText(fmt.Sprintf("'%s' seems to have moved to %s, press 'H' and then 'm' to update it", feed.Title, move.URL))
+			resetStatusBarMsg(10)
+		}
+		feed.Status.MovedTo = move.URL
+	}
+
+	// addItemNodes adds the items to the tree, leaving out the ones hidden by
+	// the rules and coloring the highlighted ones. It returns how many were added
+	addItemNodes := func(node *tview.TreeNode, items []models.Item, withFeed bool) int {
+		added := 0
+		for _, item := range items {
+			itemCopy := item
+			outcome := ruleSet.Match(itemCopy, folderOf(itemCopy.FeedURL))
+			if outcome.Hide && !itemCopy.Starred {
+				continue
+			}
+
+			color := tcell.ColorGreen
+			if outcome.Color != "" {
+				color = tcell.GetColor(outcome.Color)
+			}
+			feedItemNode := tview.NewTreeNode(itemLabel(itemCopy, withFeed)).SetReference(itemCopy)
+			feedItemNode.SetTextStyle(tcell.StyleDefault.Foreground(color).Background(tcell.Color(tcell.ColorValues[0x000000])))
+			feedItemNode.SetSelectedTextStyle(tcell.StyleDefault.Foreground(tcell.ColorBlack).Background(color))
+			node.AddChild(feedItemNode)
+			added++
+		}
+		// a feed expanded while filtering only shows the items that match
+		refilter()
+		return added
+	}
+
+	// refreshFeeds fetches all the feeds at the same time and caches their items,
+	// done is called from the ui goroutine once every fetch is over
+	refreshFeeds := func(feeds []*models.Feed, done func(failed int)) {
+		setLoading(feeds, true)
+		go func() {
+			type result struct {
+				feed    *models.Feed
+				fetched *models.Feed
+				elapsed time.Duration
+				err     error
+			}
+
+			results := make(chan result, len(feeds))
+			for _, feed := range feeds {
+				go func(feed *models.Feed) {
+					start := time.Now()
+					fetched, err := services.FetchFeed(feed)
+					results <- result{feed, fetched, time.Since(start), err}
+				}(feed)
+			}
+
+			collected := make([]result, 0, len(feeds))
+			for range feeds {
+				collected = append(collected, <-results)
+			}
+
+			app.QueueUpdateDraw(func() {
+				failed := 0
+				for _, r := range collected {
+					if r.err != nil {
+						logToFile(fmt.Sprintf("error fetching %s: %v", r.feed.URL, r.err))
+						services.RecordFetch(r.feed, r.err, r.elapsed, 0)
+						failed++
+						continue
+					}
+					services.CopySchedule(r.feed, r.fetched)
+					applyMove(r.feed, r.fetched)
+					services.RecordFetch(r.feed, nil, r.elapsed, cacheFetched(r.feed, r.fetched.Items))
+				}
+				if err := services.SaveCache(itemCache); err != nil {
+					logToFile(fmt.Sprintf("error saving cache: %v", err))
+				}
+				autoSaveFolders()
+				setLoading(feeds, false)
+				done(failed)
+			})
+		}()
+	}
+
+	// every feed is refreshed in the background so the cache stays fresh and
+	// notifications go out while the app is left open
+	if cfg.RefreshIntervalMinutes > 0 {
+		stopRefresh := services.StartRefresh(time.Duration(cfg.RefreshIntervalMinutes)*time.Minute, func() {
+			app.QueueUpdate(func() {
+				// only the feeds whose publishers are fine with being polled now
+				var feeds []*models.Feed
+				now := time.Now()
+				for _, folder := range folderData.Folders {
+					for _, feed := range folder.Feeds {
+						if services.DueForRefresh(feed, now) {
+							feeds = append(feeds, feed)
+						}
+					}
+				}
+				if len(feeds) == 0 {
+					return
+				}
+				refreshFeeds(feeds, func(failed int) {
+					if failed > 0 {
+						logToFile(fmt.Sprintf("background refresh: %d feeds failed", failed))
+					}
+				})
+			})
+		})
+		defer stopRefresh()
+	}
+
+	tree.SetSelectedFunc(func(node *tview.TreeNode) {
+		reference := node.GetReference()
+		switch v := reference.(type) {
+		case models.Item:
+			showItem(v)
+		case *riverView:
+			if len(node.GetChildren()) > 0 {
+				node.SetChildren(nil)
+				node.SetText(v.label())
+				return
+			}
+
+			if v.starred {
+				added := addItemNodes(node, services.Dedup(services.StarredItems(itemCache), cfg.DedupByTitle), true)
+				node.SetText(fmt.Sprintf("%s (%d)", v.label(), added))
+				return
+			}
+
+			// dead feeds aren't fetched but their cached items still show up
+			feeds := v.feeds(folderData)
+			alive := services.AliveFeeds(feeds)
+			statusBar.SetText(fmt.Sprintf("Refreshing %d feeds...", len(alive)))
+			refreshFeeds(alive, func(failed int) {
+				items := services.Dedup(services.RiverItems(itemCache, feeds, v.keep), cfg.DedupByTitle)
+				added := addItemNodes(node, items, true)
+				node.SetText(fmt.Sprintf("%s (%d)", v.label(), added))
+
+				if failed > 0 {
+					statusBar.SetText(fmt.Sprintf("loaded %d items, %d feeds failed to refresh", added, failed))
+				} else {
+					statusBar.SetText(fmt.Sprintf("loaded %d items for %s", added, v.label()))
+				}
+				resetStatusBarMsg(5)
+			})
+		case *models.Feed:
+			if len(node.GetChildren()) > 0 {
+				node.SetChildren(nil)
+			} else {
+				setLoading([]*models.Feed{v}, true)
+				go func() {
+					start := time.Now()
+					feedData, err := services.FetchFeed(v)
+					elapsed := time.Since(start)
+					if err != nil {
+						logToFile(fmt.Sprintf("error fetching %s: %v", v.URL, err))
+						app.QueueUpdateDraw(func() {
+							services.RecordFetch(v, err, elapsed, 0)
+							autoSaveFolders()
+							setLoading([]*models.Feed{v}, false)
+							// at least show what we've got from before
+							cached := services.CachedItems(itemCache, v.URL)
+							added := addItemNodes(node, cached, false)
+							statusBar.SetText(fmt.Sprintf("Error fetching %s, showing %d cached items", v.Title, added))
+						})
+						resetStatusBarMsg(5)
+						return
+					}
+
+					app.QueueUpdateDraw(func() {
+						services.CopySchedule(v, feedData)
+						applyMove(v, feedData)
+						services.RecordFetch(v, nil, elapsed, cacheFetched(v, feedData.Items))
+						autoSaveFolders()
+						setLoading([]*models.Feed{v}, false)
+						if err := services.SaveCache(itemCache); err != nil {
+							logToFile(fmt.Sprintf("error saving cache: %v", err))
+						}
+
+						// hidden items don't count
+						added := addItemNodes(node, feedData.Items, false)
+						statusBar.SetText(fmt.Sprintf("loaded %d items for feed: %s", added, v.Title))
+					})
+
+					resetStatusBarMsg(5)
+				}()
+			}
+		case *models.FeedFolder:
+			// handle folder selection (toggle feeds)
+			if len(node.GetChildren()) > 0 {
+				node.SetChildren(nil) // collapse here
+			} else {
+				// expand
+				node.AddChild(newRiverNode(folderRiver(v)))
+				for _, feed := range v.Feeds {
+					node.AddChild(newFeedNode(feed))
+				}
+			}
+		}
+	})
+
+	// the error of a failing feed is shown when it's selected
+	tree.SetChangedFunc(func(node *tview.TreeNode) {
+		feed, ok := node.GetReference().(*models.Feed)
+		if !ok || feed.Status == nil {
+			return
+		}
+		if feed.Status.MovedTo != "" {
+			statusBar.SetText(fmt.Sprintf("'%s' seems to have moved to %s, press 'H' and then 'm' to update it", feed.Title, feed.Status.MovedTo))
+			resetStatusBarMsg(10)
+			return
+		}
+		if feed.Status.Failures == 0 {
+			return
+		}
+		statusBar.SetText(fmt.Sprintf("Fetching '%s' failed %d time(s) in a row: %s", feed.Title, feed.Status.Failures, feed.Status.LastError))
+		resetStatusBarMsg(10)
+	})
+
+	// keeps the "updated N min ago" of the feeds current
+	stopAges := services.StartRefresh(time.Minute, func() {
+		app.QueueUpdateDraw(updateFeedNodes)
+	})
+	defer stopAges()
+
+	// adding a feed runs in the background, addCancel stops it (Esc) and is
+	// nil when nothing is being added
+	var addCancel context.CancelFunc
+
+	addFeed := func(url string, auth *models.FeedAuth) {
+		selectedNode := tree.GetCurrentNode()
+		var targetFolder *models.FeedFolder
+
+		// here i determine target folder based on selection
+		if selectedNode != nil {
+			switch v := selectedNode.GetReference().(type) {
+			case *models.FeedFolder:
+				targetFolder = v
+			case *models.Feed:
+				// if a feed is selected, gotta search through folderData to find its parent folder
+				for i := range folderData.Folders {
+					if services.FolderHasFeed(&folderData.Folders[i], v.URL) {
+						targetFolder = &folderData.Folders[i]
+						break
+					}
+				}
+			}
+		}
+
+		// if no folder is found, gotta use the first folder
+		if targetFolder == nil && len(folderData.Folders) > 0 {
+			targetFolder = &folderData.Folders[0]
+		}
+
+		if targetFolder == nil {
+			statusBar.SetText("No folder available to add feed")
+			resetStatusBarMsg(5)
+			return
+		}
+		if services.FolderHasFeed(targetFolder, url) {
+			statusBar.SetText("Error: Feed already exists!")
+			resetStatusBarMsg(5)
+			return
+		}
+		if addCancel != nil {
+			statusBar.SetText("Already adding a feed, wait for it or press ESC to cancel it")
+			resetStatusBarMsg(5)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		addCancel = cancel
+		done := make(chan struct{})
+
+		// the spinner keeps going until the result is in
+		go func() {
+			ticker := time.NewTicker(100 * time.Millisecond)
+			defer ticker.Stop()
+			for frame := 0; ; frame++ {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+					text := fmt.Sprintf("%c Adding %s to %s... (ESC to cancel)", spinnerFrames[frame%len(spinnerFrames)], tview.Escape(url), tview.Escape(targetFolder.Name))
+					app.QueueUpdateDraw(func() {
+						select {
+						case <-done:
+						default:
+							statusBar.SetText(text)
+						}
+					})
+				}
+			}
+		}()
+
+		go func() {
+			feed, message, err := services.FetchNewFeed(ctx, url, auth)
+			app.QueueUpdateDraw(func() {
+				close(done)
+				cancel()
+				addCancel = nil
+
+				if err == nil {
+					message, err = services.AddFeedToFolder(targetFolder, feed)
+				}
+				// the password is only saved now, so failed or cancelled adds
+				// don't leave it behind
+				if err == nil && auth != nil && auth.Pending != nil {
+					if saveErr := config.SaveSecret(auth.Secret, *auth.Pending); saveErr != nil {
+						logToFile(fmt.Sprintf("error saving the password of %s: %v", url, saveErr))
+						message = "Feed added, but saving its password failed: " + saveErr.Error()
+					}
+					auth.Pending = nil
+				}
+				if err != nil {
+					logToFile(fmt.Sprintf("error adding feed %s: %v", url, err))
+					statusBar.SetText("Error: " + message)
+					resetStatusBarMsg(5)
+					return
+				}
+
+				// here i add the feed node to the UI, collapsed folders show it once they're opened
+				for _, node := range root.GetChildren() {
+					if folder, ok := node.GetReference().(*models.FeedFolder); ok && folder == targetFolder {
+						if len(node.GetChildren()) > 0 {
+							node.AddChild(newFeedNode(feed))
+						}
+						break
+					}
+				}
+				refilter()
+				statusBar.SetText(message)
+				resetStatusBarMsg(5)
+			})
+		}()
+	}
+
+	addFeedForm := tview.NewForm()
+	addFeedForm.AddInputField("RSS Feed URL: ", "", 0, nil, nil)
+	addFeedForm.AddInputField("Username (optional): ", "", 0, nil, nil)
+	addFeedForm.AddPasswordField("Password/token (optional): ", "", 0, '*', nil)
+	addFeedForm.AddButton("Add", func() {
+		url := strings.TrimSpace(addFeedForm.GetFormItem(0).(*tview.InputField).GetText())
+		username := strings.TrimSpace(addFeedForm.GetFormItem(1).(*tview.InputField).GetText())
+		secret := addFeedForm.GetFormItem(2).(*tview.InputField).GetText()
+		if url == "" {
+			return
+		}
+
+		// the password goes to secrets.json under the feed url once the feed
+		// is added, feeds.json only knows its name. A username means basic
+		// auth, otherwise it's a bearer token
+		var auth *models.FeedAuth
+		if username != "" || secret != "" {
+			auth = &models.FeedAuth{Type: "bearer", Secret: url, Pending: &secret}
+			if username != "" {
+				auth.Type = "basic"
+				auth.Username = username
+			}
+		}
+
+		pages.HidePage("addFeed")
+		app.SetFocus(tree)
+		addFeed(url, auth)
+	})
+
+	addFeedForm.SetButtonsAlign(1)
+
+	addFeedForm.SetBackgroundColor(tcell.Color(tcell.ColorValues[0x000000]))
+	addFeedForm.SetBorderStyle(tcell.StyleDefault.Foreground(tcell.ColorGreen))
+	addFeedForm.SetTitleColor(tcell.ColorGreen)
+	addFeedForm.SetFieldBackgroundColor(tcell.Color(tcell.ColorValues[0x000000]))
+	addFeedForm.SetFieldTextColor(tcell.ColorGreen)
+	addFeedForm.SetButtonTextColor(tcell.ColorGreen)
+	addFeedForm.SetButtonBackgroundColor(tcell.ColorBlack)
+
+	closingTipText := tview.NewTextView().
+		SetText("Tip: Press 'ESC' to close this window")
+
+	closingTipText.SetBackgroundColor(tcell.Color(tcell.ColorValues[0x000000]))
+	closingTipText.SetTextAlign(1)
+
+	addFeedFormLayout := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(addFeedForm, 0, 1, true).
+		AddItem(closingTipText, 1, 0, false)
+
+	addFeedFormLayout.SetBorder(true).
+		SetBorderStyle(tcell.StyleDefault.Foreground(tcell.ColorGreen)).
+		SetTitle("Add a new feed").
+		SetTitleColor(tcell.ColorGreen)
+
+	// flex container to center the form
+	formFlex.AddItem(nil, 0, 1, false)
+	formFlex.AddItem(tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(addFeedFormLayout, 70, 1, true).
+		AddItem(nil, 0, 1, false), 12, 1, true).
+		AddItem(nil, 0, 1, false)
+
+	addFeedForm.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEsc {
+			pages.HidePage("addFeed")
+			app.SetFocus(tree)
+			return nil
+		}
+		return event
+	})
+
+	addFolderForm := tview.NewForm()
+	addFolderForm.SetFieldBackgroundColor(tcell.Color(tcell.ColorValues[0x000000]))
+	addFolderForm.SetFieldTextColor(tcell.ColorGreen)
+	addFolderForm.AddInputField("Folder Name: ", "", 0, nil, nil)
+	addFolderForm.SetBackgroundColor(tcell.Color(tcell.ColorValues[0x000000]))
+	addFolderForm.AddButton("Add", func() {
+		folderName := addFolderForm.GetFormItem(0).(*tview.InputField).GetText()
+		if folderName != "" {
+			for _, folder := range folderData.Folders {
+				if folder.Name == folderName {
+					statusBar.SetText("Folder already exists!")
+					resetStatusBarMsg(5)
+					return
+				}
+			}
+
+			newFolder := models.FeedFolder{
+				Name:  folderName,
+				Feeds: []*models.Feed{},
+			}
+
+			folderData.Folders = append(folderData.Folders, newFolder)
+			addedFolder := &folderData.Folders[len(folderData.Folders)-1]
+
+			newFolderNode := tview.NewTreeNode(folderName).SetReference(addedFolder)
+			newFolderNode.SetColor(tcell.ColorGreen)
+			newFolderNode.SetTextStyle(tcell.StyleDefault.Foreground(tcell.ColorGreen).Background(tcell.Color(tcell.ColorValues[0x000000])))
+			newFolderNode.SetSelectedTextStyle(tcell.StyleDefault.Foreground(tcell.ColorBlack).Background(tcell.ColorGreen))
+			root.AddChild(newFolderNode)
+			addedFolder.FolderNode = newFolderNode
+			refilter()
+
+			saveFolders()
+			statusBar.SetText(fmt.Sprintf("Folder '%s' created successfully!", folderName))
+			resetStatusBarMsg(5)
+		}
+		pages.HidePage("addFolder")
+		app.SetFocus(tree)
+	})
+	addFolderForm.SetButtonsAlign(1)
+	addFolderForm.SetButtonTextColor(tcell.ColorGreen)
+	addFolderForm.SetButtonBackgroundColor(tcell.ColorBlack)
+
+	folderFormTipText := tview.NewTextView()
+	folderFormTipText.SetText("Tip: Press 'ESC' to close")
+	folderFormTipText.SetTextAlign(1)
+	folderFormTipText.SetBackgroundColor(tcell.Color(tcell.ColorValues[0x000000]))
+
+	folderFormLayout := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(addFolderForm, 5, 1, true).
+		AddItem(folderFormTipText, 1, 0, false)
+	folderFormLayout.SetBorder(true).
 		SetBorderStyle(tcell.StyleDefault.Foreground(tcell.ColorGreen)).
 		SetTitle("Add a new folder").
 		SetTitleColor(tcell.ColorGreen)
@@ -411,153 +1176,619 @@ func SetupUI(folderData *models.FolderData) *tview.Pages {
 		return event
 	})
 
-	addFeedForm.GetButton(0).SetSelectedFunc(func() {
-		url := addFeedForm.GetFormItem(0).(*tview.InputField).GetText()
-		if url == "" {
-			return
+	showRenameFolderModal := func(folder *models.FeedFolder, node *tview.TreeNode) {
+		if pages.HasPage("renameFolder") {
+			pages.RemovePage("renameFolder")
 		}
 
-		selectedNode := tree.GetCurrentNode()
-		var targetFolder *models.FeedFolder
+		renameForm := tview.NewForm()
+		renameForm.AddInputField("New Name: ", folder.Name, 0, nil, nil)
+		renameForm.AddButton("Rename", func() {
+			newName := renameForm.GetFormItem(0).(*tview.InputField).GetText()
+			if newName == "" {
+				statusBar.SetText("Folder name cannot be empty")
+				resetStatusBarMsg(5)
+				return
+			}
 
-		// here i determine target folder based on selection
-		if selectedNode != nil {
-			ref := selectedNode.GetReference()
-			switch v := ref.(type) {
-			case *models.FeedFolder:
-				targetFolder = v
-			case *models.Feed:
-				// if a feed is selected, will use the root's first child (folder)
-				if len(root.GetChildren()) > 0 {
-					if folder, ok := root.GetChildren()[0].GetReference().(*models.FeedFolder); ok {
-						targetFolder = folder
-					}
+			for _, f := range folderData.Folders {
+				if f.Name == newName && &f != folder {
+					statusBar.SetText("Folder name already exists")
+					resetStatusBarMsg(5)
+					return
 				}
 			}
+
+			folder.Name = newName
+			node.SetText(newName)
+			saveFolders()
+			pages.HidePage("renameFolder")
+			app.SetFocus(tree)
+			statusBar.SetText(fmt.Sprintf("Folder renamed to '%s'", newName))
+			resetStatusBarMsg(5)
+		})
+
+		renameForm.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+			if event.Key() == tcell.KeyEsc {
+				pages.HidePage("renameFolder")
+				app.SetFocus(tree)
+				return nil
+			}
+			return event
+		})
+
+		tipText := tview.NewTextView()
+		tipText.SetText("Tip: Press 'ESC' to close")
+		tipText.SetTextAlign(1)
+		tipText.SetBackgroundColor(tcell.Color(tcell.ColorValues[0x000000]))
+		renameForm.SetButtonsAlign(1)
+		renameForm.SetBackgroundColor(tcell.Color(tcell.ColorValues[0x000000]))
+		renameForm.SetBorderStyle(tcell.StyleDefault.Foreground(tcell.ColorGreen))
+		renameForm.SetTitleColor(tcell.ColorGreen)
+		renameForm.SetFieldBackgroundColor(tcell.Color(tcell.ColorValues[0x000000]))
+		renameForm.SetFieldTextColor(tcell.ColorGreen)
+		renameForm.SetButtonTextColor(tcell.ColorGreen)
+		renameForm.SetButtonBackgroundColor(tcell.ColorBlack)
+
+		renameFormLayout := tview.NewFlex().
+			SetDirection(tview.FlexRow).
+			AddItem(renameForm, 5, 1, true).
+			AddItem(tipText, 1, 0, false)
+
+		renameFormLayout.SetBorder(true).
+			SetBorderStyle(tcell.StyleDefault.Foreground(tcell.ColorGreen)).
+			SetTitle("Rename Folder").
+			SetTitleColor(tcell.ColorGreen)
+
+		renameFlex := tview.NewFlex().
+			AddItem(nil, 0, 1, false).
+			AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+				AddItem(nil, 0, 1, false).
+				AddItem(tview.NewFlex().
+					AddItem(nil, 0, 1, false).
+					AddItem(renameFormLayout, 70, 1, true).
+					AddItem(nil, 0, 1, false),
+					8, 1, true).
+				AddItem(nil, 0, 1, false),
+				0, 1, true).
+			AddItem(nil, 0, 1, false)
+
+		pages.AddPage("renameFolder", renameFlex, true, false)
+		pages.ShowPage("renameFolder")
+		app.SetFocus(renameForm.GetFormItem(0).(*tview.InputField))
+	}
+
+	searchInput := tview.NewInputField()
+	searchInput.SetLabel("Search: ")
+	searchInput.SetFieldBackgroundColor(tcell.Color(tcell.ColorValues[0x000000]))
+	searchInput.SetFieldTextColor(tcell.ColorGreen)
+	searchInput.SetLabelColor(tcell.ColorGreen)
+	searchInput.SetBackgroundColor(tcell.Color(tcell.ColorValues[0x000000]))
+
+	searchResults := tview.NewList()
+	searchResults.SetBackgroundColor(tcell.Color(tcell.ColorValues[0x000000]))
+	searchResults.SetMainTextColor(tcell.ColorGreen)
+	searchResults.SetSecondaryTextColor(tcell.ColorGray)
+	searchResults.SetSelectedTextColor(tcell.ColorBlack)
+	searchResults.SetSelectedBackgroundColor(tcell.ColorGreen)
+
+	searchLayout := tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(searchInput, 1, 0, true).
+		AddItem(searchResults, 0, 1, false)
+	searchLayout.SetBorder(true).
+		SetBorderStyle(tcell.StyleDefault.Foreground(tcell.ColorGreen)).
+		SetTitle("Search (Enter: go to results | ESC: close)").
+		SetTitleColor(tcell.ColorGreen)
+
+	searchFlex := tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(searchLayout, 0, 4, true).
+			AddItem(nil, 0, 1, false),
+			0, 4, true).
+		AddItem(nil, 0, 1, false)
+
+	pages.AddPage("search", searchFlex, true, false)
+
+	closeSearch := func() {
+		pages.HidePage("search")
+		app.SetFocus(tree)
+	}
+
+	// results are refreshed as you type, the index is in memory so it's cheap
+	searchInput.SetChangedFunc(func(query string) {
+		searchResults.Clear()
+		results := searchIndex.Search(query)
+		for _, item := range results {
+			item := item
+			secondary := fmt.Sprintf("  %s | %s", tview.Escape(item.FeedTitle), tview.Escape(item.PubDate))
+			searchResults.AddItem(tview.Escape(item.Title), secondary, 0, func() {
+				closeSearch()
+				showItem(item)
+			})
+		}
+		if query != "" {
+			searchLayout.SetTitle(fmt.Sprintf("Search - %d results (Enter: go to results | ESC: close)", len(results)))
+		}
+	})
+
+	searchInput.SetDoneFunc(func(key tcell.Key) {
+		switch key {
+		case tcell.KeyEnter:
+			if searchResults.GetItemCount() > 0 {
+				app.SetFocus(searchResults)
+			}
+		case tcell.KeyEsc:
+			closeSearch()
 		}
+	})
 
-		// if no folder is selected/found, use the first available folder
-		if targetFolder == nil && len(folderData.Folders) > 0 {
-			targetFolder = &folderData.Folders[0]
+	searchResults.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyEsc:
+			closeSearch()
+			return nil
+		case tcell.KeyTab:
+			app.SetFocus(searchInput)
+			return nil
+		}
+		switch event.Rune() {
+		case 'j':
+			return tcell.NewEventKey(tcell.KeyDown, 0, tcell.ModNone)
+		case 'k':
+			return tcell.NewEventKey(tcell.KeyUp, 0, tcell.ModNone)
+		case '/':
+			app.SetFocus(searchInput)
+			return nil
 		}
+		return event
+	})
 
-		if targetFolder == nil {
-			statusBar.SetText("No folder available to add feed")
-			resetStatusBarMsg(5)
+	filterInput := tview.NewInputField()
+	filterInput.SetLabel("Filter: ")
+	filterInput.SetFieldBackgroundColor(tcell.Color(tcell.ColorValues[0x000000]))
+	filterInput.SetFieldTextColor(tcell.ColorGreen)
+	filterInput.SetLabelColor(tcell.ColorGreen)
+	filterInput.SetBackgroundColor(tcell.Color(tcell.ColorValues[0x000000]))
+
+	closeFilterInput := func() {
+		appFlex.RemoveItem(filterInput)
+		app.SetFocus(tree)
+	}
+
+	filterInput.SetChangedFunc(func(query string) {
+		if filterOpen == nil {
 			return
 		}
-
-		// here we feed to selected folder
-		feed, message, err := services.AddFeedToFolder(targetFolder, url)
-		if err != nil {
-			statusBar.SetText("Error: " + message)
-			resetStatusBarMsg(5)
+		filterTree(query)
+		if query == "" {
+			tree.SetTitle("Core RSS")
 		} else {
-			// find the folder node and add the feed
-			for _, node := range root.GetChildren() {
-				if folder, ok := node.GetReference().(*models.FeedFolder); ok {
-					if folder == targetFolder {
-						feedNode := tview.NewTreeNode(feed.Title).SetReference(feed)
-						feedNode.SetColor(tcell.ColorGreen)
-						node.AddChild(feedNode)
-						break
-					}
+			tree.SetTitle(fmt.Sprintf("Core RSS - filter: %s", query))
+		}
+		tree.SetCurrentNode(root)
+	})
+
+	// Enter keeps the tree filtered so you can navigate it, ESC brings
+	// everything back
+	filterInput.SetDoneFunc(func(key tcell.Key) {
+		switch key {
+		case tcell.KeyEnter:
+			if filterInput.GetText() == "" {
+				restoreTree()
+			}
+			closeFilterInput()
+		case tcell.KeyEsc:
+			restoreTree()
+			closeFilterInput()
+		}
+	})
+
+	showFilterInput := func() {
+		if filterOpen == nil {
+			startFilter()
+			filterInput.SetText("")
+		}
+		appFlex.RemoveItem(filterInput)
+		appFlex.RemoveItem(statusBar)
+		appFlex.AddItem(filterInput, 1, 0, false)
+		appFlex.AddItem(statusBar, 1, 1, false)
+		app.SetFocus(filterInput)
+	}
+
+	// the health dashboard lists every feed with how its fetches went, to
+	// find the dead ones. Feeds can be marked with space for the bulk actions
+	healthTable := tview.NewTable()
+	healthTable.SetSelectable(true, false)
+	healthTable.SetFixed(1, 0)
+	healthTable.SetBackgroundColor(tcell.Color(tcell.ColorValues[0x000000]))
+	healthTable.SetSelectedStyle(tcell.StyleDefault.Foreground(tcell.ColorBlack).Background(tcell.ColorGreen))
+	healthTable.SetBorder(true)
+	healthTable.SetBorderStyle(tcell.StyleDefault.Foreground(tcell.ColorGreen))
+	healthTable.SetTitle("Feed health (1-8: sort | space: mark | r: retry | e: edit url | m: accept move | d: remove | ESC: close)")
+	healthTable.SetTitleColor(tcell.ColorGreen)
+	pages.AddPage("health", healthTable, true, false)
+
+	healthSort, healthReverse := 4, true // most failures first
+	healthMarked := map[*models.Feed]bool{}
+	var healthRows []healthRow
+
+	drawHealth := func() {
+		healthRows = healthRows[:0]
+		for _, folder := range folderData.Folders {
+			for _, feed := range folder.Feeds {
+				perWeek := services.ItemsPerWeek(services.CachedItems(itemCache, feed.URL))
+				healthRows = append(healthRows, healthRow{feed: feed, folder: folder.Name, perWeek: perWeek})
+			}
+		}
+		sortHealthRows(healthRows, healthSort, healthReverse)
+
+		selected, _ := healthTable.GetSelection()
+		healthTable.Clear()
+		for column, name := range healthColumns {
+			if column == healthSort {
+				if healthReverse {
+					name += " ▼"
+				} else {
+					name += " ▲"
 				}
 			}
-			statusBar.SetText(message)
-			resetStatusBarMsg(5)
+			healthTable.SetCell(0, column, tview.NewTableCell(fmt.Sprintf("%d %s", column+1, name)).
+				SetTextColor(tcell.ColorYellow).SetSelectable(false))
 		}
-		pages.HidePage("addFeed")
+
+		now := time.Now()
+		for i, row := range healthRows {
+			color := feedColor(row.feed)
+			for column, text := range row.cells(now) {
+				if column == 0 && healthMarked[row.feed] {
+					text = "+ " + text
+				}
+				healthTable.SetCell(i+1, column, tview.NewTableCell(tview.Escape(text)).SetTextColor(color).SetExpansion(1))
+			}
+		}
+		if selected < 1 {
+			selected = 1
+		}
+		healthTable.Select(min(selected, len(healthRows)), 0)
+	}
+
+	closeHealth := func() {
+		pages.HidePage("health")
 		app.SetFocus(tree)
-	})
+	}
+
+	showHealth := func() {
+		healthMarked = map[*models.Feed]bool{}
+		drawHealth()
+		pages.ShowPage("health")
+		app.SetFocus(healthTable)
+	}
+
+	// healthTargets are the marked feeds, or the one under the cursor
+	healthTargets := func() []*models.Feed {
+		var feeds []*models.Feed
+		for _, row := range healthRows {
+			if healthMarked[row.feed] {
+				feeds = append(feeds, row.feed)
+			}
+		}
+		if len(feeds) == 0 {
+			if selected, _ := healthTable.GetSelection(); selected > 0 && selected <= len(healthRows) {
+				feeds = append(feeds, healthRows[selected-1].feed)
+			}
+		}
+		return feeds
+	}
+
+	removeFeedNode := func(folder *models.FeedFolder, feed *models.Feed) {
+		if folder.FolderNode == nil {
+			return
+		}
+		for _, child := range folder.FolderNode.GetChildren() {
+			if child.GetReference() == feed {
+				folder.FolderNode.RemoveChild(child)
+				break
+			}
+		}
+		refilter()
+	}
+
+	// forgetSecret deletes the password of a removed feed from secrets.json.
+	// Only the ones the add form saved (named after the url the feed was added
+	// with) and that no other feed uses, the others were set up by hand
+	forgetSecret := func(feed *models.Feed) {
+		if feed.Auth == nil || !strings.Contains(feed.Auth.Secret, "://") {
+			return
+		}
+		for _, folder := range folderData.Folders {
+			for _, other := range folder.Feeds {
+				if other.Auth != nil && other.Auth.Secret == feed.Auth.Secret {
+					return
+				}
+			}
+		}
+		if err := config.DeleteSecret(feed.Auth.Secret); err != nil {
+			logToFile(fmt.Sprintf("error deleting the password of %s: %v", feed.URL, err))
+		}
+	}
+
+	editFeedURL := func(feed *models.Feed) {
+		if pages.HasPage("editFeedUrl") {
+			pages.RemovePage("editFeedUrl")
+		}
 
-	showRenameFolderModal := func(folder *models.FeedFolder, node *tview.TreeNode) {
-		if pages.HasPage("renameFolder") {
-			pages.RemovePage("renameFolder")
+		closeEdit := func() {
+			pages.RemovePage("editFeedUrl")
+			app.SetFocus(healthTable)
 		}
 
-		renameForm := tview.NewForm()
-		renameForm.AddInputField("New Name: ", folder.Name, 0, nil, nil)
-		renameForm.AddButton("Rename", func() {
-			newName := renameForm.GetFormItem(0).(*tview.InputField).GetText()
-			if newName == "" {
-				statusBar.SetText("Folder name cannot be empty")
+		editForm := tview.NewForm()
+		editForm.AddInputField("URL: ", feed.URL, 0, nil, nil)
+		editForm.AddButton("Save", func() {
+			newUrl := strings.TrimSpace(editForm.GetFormItem(0).(*tview.InputField).GetText())
+			if newUrl == "" {
+				statusBar.SetText("Feed URL cannot be empty")
 				resetStatusBarMsg(5)
 				return
 			}
 
-			for _, f := range folderData.Folders {
-				if f.Name == newName && &f != folder {
-					statusBar.SetText("Folder name already exists")
-					resetStatusBarMsg(5)
-					return
-				}
+			moveFeed(feed, newUrl)
+			// a new url starts with a clean slate
+			feed.Status = nil
+			saveFolders()
+			if err := services.SaveCache(itemCache); err != nil {
+				logToFile(fmt.Sprintf("error saving cache: %v", err))
 			}
-
-			folder.Name = newName
-			node.SetText(newName)
-			services.SaveFolders(folderData)
-			pages.HidePage("renameFolder")
-			app.SetFocus(tree)
-			statusBar.SetText(fmt.Sprintf("Folder renamed to '%s'", newName))
+			closeEdit()
+			drawHealth()
+			updateFeedNodes()
+			statusBar.SetText(fmt.Sprintf("URL of '%s' changed to %s", feed.Title, newUrl))
 			resetStatusBarMsg(5)
 		})
-
-		renameForm.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		editForm.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
 			if event.Key() == tcell.KeyEsc {
-				pages.HidePage("renameFolder")
-				app.SetFocus(tree)
+				closeEdit()
 				return nil
 			}
 			return event
 		})
-
-		tipText := tview.NewTextView()
-		tipText.SetText("Tip: Press 'ESC' to close")
-		tipText.SetTextAlign(1)
-		tipText.SetBackgroundColor(tcell.Color(tcell.ColorValues[0x000000]))
-		renameForm.SetButtonsAlign(1)
-		renameForm.SetBackgroundColor(tcell.Color(tcell.ColorValues[0x000000]))
-		renameForm.SetBorderStyle(tcell.StyleDefault.Foreground(tcell.ColorGreen))
-		renameForm.SetTitleColor(tcell.ColorGreen)
-		renameForm.SetFieldBackgroundColor(tcell.Color(tcell.ColorValues[0x000000]))
-		renameForm.SetFieldTextColor(tcell.ColorGreen)
-		renameForm.SetButtonTextColor(tcell.ColorGreen)
-		renameForm.SetButtonBackgroundColor(tcell.ColorBlack)
-
-		renameFormLayout := tview.NewFlex().
-			SetDirection(tview.FlexRow).
-			AddItem(renameForm, 5, 1, true).
-			AddItem(tipText, 1, 0, false)
-
-		renameFormLayout.SetBorder(true).
+		editForm.SetButtonsAlign(1)
+		editForm.SetBackgroundColor(tcell.Color(tcell.ColorValues[0x000000]))
+		editForm.SetFieldBackgroundColor(tcell.Color(tcell.ColorValues[0x000000]))
+		editForm.SetFieldTextColor(tcell.ColorGreen)
+		editForm.SetButtonTextColor(tcell.ColorGreen)
+		editForm.SetButtonBackgroundColor(tcell.ColorBlack)
+		editForm.SetBorder(true).
 			SetBorderStyle(tcell.StyleDefault.Foreground(tcell.ColorGreen)).
-			SetTitle("Rename Folder").
+			SetTitle("Edit URL of " + feed.Title).
 			SetTitleColor(tcell.ColorGreen)
 
-		renameFlex := tview.NewFlex().
+		editFlex := tview.NewFlex().
 			AddItem(nil, 0, 1, false).
 			AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
 				AddItem(nil, 0, 1, false).
-				AddItem(tview.NewFlex().
-					AddItem(nil, 0, 1, false).
-					AddItem(renameFormLayout, 70, 1, true).
-					AddItem(nil, 0, 1, false),
-					8, 1, true).
+				AddItem(editForm, 7, 1, true).
 				AddItem(nil, 0, 1, false),
-				0, 1, true).
+				0, 3, true).
 			AddItem(nil, 0, 1, false)
 
-		pages.AddPage("renameFolder", renameFlex, true, false)
-		pages.ShowPage("renameFolder")
-		app.SetFocus(renameForm.GetFormItem(0).(*tview.InputField))
+		pages.AddPage("editFeedUrl", editFlex, true, true)
+		app.SetFocus(editForm)
 	}
 
+	healthTable.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEsc {
+			closeHealth()
+			return nil
+		}
+
+		switch event.Rune() {
+		case 'j':
+			return tcell.NewEventKey(tcell.KeyDown, 0, tcell.ModNone)
+		case 'k':
+			return tcell.NewEventKey(tcell.KeyUp, 0, tcell.ModNone)
+		case '1', '2', '3', '4', '5', '6', '7', '8':
+			// the same column again flips the order
+			column := int(event.Rune() - '1')
+			if column == healthSort {
+				healthReverse = !healthReverse
+			} else {
+				healthSort, healthReverse = column, false
+			}
+			drawHealth()
+			return nil
+		case ' ':
+			if selected, _ := healthTable.GetSelection(); selected > 0 && selected <= len(healthRows) {
+				feed := healthRows[selected-1].feed
+				healthMarked[feed] = !healthMarked[feed]
+				if !healthMarked[feed] {
+					delete(healthMarked, feed)
+				}
+				drawHealth()
+				healthTable.Select(min(selected+1, len(healthRows)), 0)
+			}
+			return nil
+		case 'r':
+			feeds := healthTargets()
+			if len(feeds) == 0 {
+				return nil
+			}
+			statusBar.SetText(fmt.Sprintf("Retrying %d feeds...", len(feeds)))
+			refreshFeeds(feeds, func(failed int) {
+				drawHealth()
+				statusBar.SetText(fmt.Sprintf("Retried %d feeds, %d still failing", len(feeds), failed))
+				resetStatusBarMsg(5)
+			})
+			return nil
+		case 'm':
+			moved := 0
+			for _, feed := range healthTargets() {
+				if feed.Status != nil && feed.Status.MovedTo != "" {
+					moveFeed(feed, feed.Status.MovedTo)
+					moved++
+				}
+			}
+			if moved == 0 {
+				statusBar.SetText("None of these feeds moved")
+			} else {
+				saveFolders()
+				if err := services.SaveCache(itemCache); err != nil {
+					logToFile(fmt.Sprintf("error saving cache: %v", err))
+				}
+				updateFeedNodes()
+				drawHealth()
+				statusBar.SetText(fmt.Sprintf("Updated the url of %d feed(s)", moved))
+			}
+			resetStatusBarMsg(5)
+			return nil
+		case 'e':
+			if selected, _ := healthTable.GetSelection(); selected > 0 && selected <= len(healthRows) {
+				editFeedURL(healthRows[selected-1].feed)
+			}
+			return nil
+		case 'd':
+			feeds := healthTargets()
+			if len(feeds) == 0 {
+				return nil
+			}
+			confirmModal.SetText(fmt.Sprintf("Are you sure you want to remove %d feed(s)?", len(feeds)))
+			confirmModal.SetDoneFunc(func(buttonIndex int, buttonLabel string) {
+				if buttonLabel == "Yes" {
+					for _, feed := range feeds {
+						if folder := services.RemoveFeed(folderData, feed); folder != nil {
+							removeFeedNode(folder, feed)
+							forgetSecret(feed)
+						}
+						delete(healthMarked, feed)
+					}
+					saveFolders()
+					statusBar.SetText(fmt.Sprintf("Removed %d feed(s)", len(feeds)))
+					resetStatusBarMsg(5)
+					drawHealth()
+				}
+				pages.RemovePage("confirmRemove")
+				app.SetFocus(healthTable)
+			})
+			pages.AddPage("confirmRemove", confirmModal, true, true)
+			app.SetFocus(confirmModal)
+			return nil
+		}
+		return event
+	})
+
 	app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
 		if _, isInputField := app.GetFocus().(*tview.InputField); isInputField {
 			return event
 		}
+		if _, isList := app.GetFocus().(*tview.List); isList {
+			// the search results handle their own keys
+			return event
+		}
+		if app.GetFocus() == healthTable {
+			return event
+		}
+		if event.Key() == tcell.KeyEsc && addCancel != nil {
+			addCancel()
+			return nil
+		}
+		if event.Key() == tcell.KeyEsc && filterOpen != nil && app.GetFocus() == tree {
+			restoreTree()
+			return nil
+		}
 		switch event.Rune() {
+		case 'N':
+			selectedNode := tree.GetCurrentNode()
+			if selectedNode != nil && app.GetFocus() == tree {
+				if feed, ok := selectedNode.GetReference().(*models.Feed); ok {
+					feed.Notify = !feed.Notify
+					saveFolders()
+					if feed.Notify {
+						statusBar.SetText(fmt.Sprintf("You'll be notified of new items in '%s'", feed.Title))
+					} else {
+						statusBar.SetText(fmt.Sprintf("No more notifications for '%s'", feed.Title))
+					}
+					resetStatusBarMsg(5)
+					return nil
+				}
+			}
+			statusBar.SetText("No feed selected")
+			resetStatusBarMsg(5)
+			return nil
+		case 'E':
+			selectedNode := tree.GetCurrentNode()
+			if selectedNode != nil && app.GetFocus() == tree {
+				if feed, ok := selectedNode.GetReference().(*models.Feed); ok {
+					feed.FullText = !feed.FullText
+					saveFolders()
+					if feed.FullText {
+						statusBar.SetText(fmt.Sprintf("Full articles will be loaded for '%s'", feed.Title))
+					} else {
+						statusBar.SetText(fmt.Sprintf("Full articles won't be loaded for '%s' anymore", feed.Title))
+					}
+					resetStatusBarMsg(5)
+					return nil
+				}
+			}
+			statusBar.SetText("No feed selected")
+			resetStatusBarMsg(5)
+			return nil
+		case 's':
+			// the item under the cursor wins, otherwise the one being read
+			selectedNode := tree.GetCurrentNode()
+			var target *models.Item
+			if selectedNode != nil {
+				if item, ok := selectedNode.GetReference().(models.Item); ok {
+					target = &item
+				}
+			}
+			if target == nil {
+				target = currentItem
+			}
+			if target == nil {
+				statusBar.SetText("No item selected to star")
+				resetStatusBarMsg(5)
+				return nil
+			}
+
+			starred, err := services.ToggleStar(itemCache, *target)
+			if err != nil {
+				statusBar.SetText("Error: " + err.Error())
+				resetStatusBarMsg(5)
+				return nil
+			}
+			if err := services.SaveCache(itemCache); err != nil {
+				logToFile(fmt.Sprintf("error saving cache: %v", err))
+			}
+
+			target.Starred = starred
+			if currentItem != nil && services.ItemKey(*currentItem) == services.ItemKey(*target) {
+				currentItem.Starred = starred
+			}
+			if selectedNode != nil {
+				if item, ok := selectedNode.GetReference().(models.Item); ok && services.ItemKey(item) == services.ItemKey(*target) {
+					inRiver := selectedNode.GetText() == itemLabel(item, true)
+					selectedNode.SetReference(*target)
+					selectedNode.SetText(itemLabel(*target, inRiver))
+				}
+			}
+
+			if starred {
+				statusBar.SetText(fmt.Sprintf("Starred '%s'", target.Title))
+			} else {
+				statusBar.SetText(fmt.Sprintf("Unstarred '%s'", target.Title))
+			}
+			resetStatusBarMsg(5)
+			return nil
+		case 'F':
+			showFilterInput()
+			return nil
+		case '/':
+			pages.ShowPage("search")
+			app.SetFocus(searchInput)
+			return nil
 		case 'r':
 			selectedNode := tree.GetCurrentNode()
 			if selectedNode != nil {
@@ -577,12 +1808,17 @@ func SetupUI(folderData *models.FolderData) *tview.Pages {
 			return nil
 		case 'a':
 			pages.ShowPage("addFeed")
-			addFeedForm.GetFormItem(0).(*tview.InputField).SetText("")
+			for i := 0; i < 3; i++ {
+				addFeedForm.GetFormItem(i).(*tview.InputField).SetText("")
+			}
 			app.SetFocus(addFeedForm.GetFormItem(0).(*tview.InputField))
 			return nil
 		case 'q':
 			app.Stop()
 			return nil
+		case 'H':
+			showHealth()
+			return nil
 		case '?':
 			pages.AddPage("help", helpModal, true, true)
 			app.SetFocus(helpModal)
@@ -595,24 +1831,12 @@ func SetupUI(folderData *models.FolderData) *tview.Pages {
 					confirmModal.SetDoneFunc(func(buttonIndex int, buttonLabel string) {
 						if buttonLabel == "Yes" {
 							//here i find the folder containing this feed
-							var targetFolder *models.FeedFolder
-							for i := range folderData.Folders {
-								folder := &folderData.Folders[i]
-								for j, f := range folder.Feeds {
-									if f == feed {
-										folder.Feeds = append(folder.Feeds[:j], folder.Feeds[j+1:]...)
-										targetFolder = folder
-										break
-									}
-								}
-								if targetFolder != nil {
-									break
-								}
-							}
-
+							targetFolder := services.RemoveFeed(folderData, feed)
 							if targetFolder != nil && targetFolder.FolderNode != nil {
 								targetFolder.FolderNode.RemoveChild(selectedNode)
-								services.SaveFolders(folderData)
+								refilter()
+								saveFolders()
+								forgetSecret(feed)
 								statusBar.SetText(fmt.Sprintf("Feed '%s' removed.", feed.Title))
 								contentView.Clear()
 							}
@@ -645,25 +1869,271 @@ func SetupUI(folderData *models.FolderData) *tview.Pages {
 		app.SetFocus(appFlex)
 	})
 
-	openURL := func(rawUrl string) error {
-		parsedURL, err := url.Parse(rawUrl)
-		if err != nil {
-			return err
+	urlOpener, err := opener.New(cfg.Openers)
+	if err != nil {
+		logToFile(fmt.Sprintf("error in the openers from config.json: %v", err))
+	}
+	openURL := urlOpener.Open
+
+	// addLinkAsFeed puts a link of an article in the add feed form, only web,
+	// gemini and gopher ones: articles must never be able to add a command feed
+	addLinkAsFeed := func(link string) {
+		parsed, err := url.Parse(link)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https" && parsed.Scheme != "gemini" && parsed.Scheme != "gopher") {
+			statusBar.SetText("Only http(s), gemini and gopher links can be added as feeds")
+			resetStatusBarMsg(5)
+			return
+		}
+		pages.ShowPage("addFeed")
+		addFeedForm.GetFormItem(0).(*tview.InputField).SetText(link)
+		addFeedForm.GetFormItem(1).(*tview.InputField).SetText("")
+		addFeedForm.GetFormItem(2).(*tview.InputField).SetText("")
+		app.SetFocus(addFeedForm.GetFormItem(0).(*tview.InputField))
+	}
+
+	imageProtocol := termimg.Detect(cfg.ImageProtocol)
+
+	// viewImage shows the image in the terminal when it supports kitty or
+	// sixel graphics, otherwise it's opened externally
+	viewImage := func(src string) {
+		if imageProtocol == termimg.None {
+			if err := openURL(src); err != nil {
+				statusBar.SetText("Error opening image: " + err.Error())
+			} else {
+				statusBar.SetText("The terminal can't show images, opening it externally...")
+			}
+			resetStatusBarMsg(5)
+			return
 		}
 
-		if parsedURL.Scheme == "" {
-			parsedURL.Scheme = "http"
+		statusBar.SetText("Loading image...")
+		go func() {
+			img, err := termimg.Fetch(src)
+			if err != nil {
+				app.QueueUpdateDraw(func() {
+					statusBar.SetText("Error loading image: " + err.Error())
+				})
+				resetStatusBarMsg(5)
+				return
+			}
+
+			app.QueueUpdate(func() {
+				app.Suspend(func() {
+					fmt.Print("\x1b[2J\x1b[H")
+					if err := termimg.Draw(os.Stdout, img, imageProtocol, 800); err != nil {
+						fmt.Println("Error showing image:", err)
+					}
+					fmt.Print("\nPress Enter to go back")
+					bufio.NewReader(os.Stdin).ReadString('\n')
+				})
+				statusBar.SetText(defaultStatusBarMsg)
+			})
+		}()
+	}
+
+	// linkAction runs one of the link picker actions: 'o' opens, 'y' copies,
+	// 'A' adds the link as a feed and 'i' shows images
+	linkAction := func(action rune, link string) {
+		switch action {
+		case 'i':
+			viewImage(link)
+			return
+		case 'o':
+			if err := openURL(link); err != nil {
+				statusBar.SetText("Error opening URL: " + err.Error())
+			} else {
+				statusBar.SetText("Opening " + link)
+			}
+		case 'y':
+			if err := clipboard.Copy(screen, link); err != nil {
+				statusBar.SetText("Error copying link: " + err.Error())
+			} else {
+				statusBar.SetText("Copied " + link)
+			}
+		case 'A':
+			addLinkAsFeed(link)
+			return
 		}
+		resetStatusBarMsg(5)
+	}
 
-		cmd := exec.Command("xdg-open", parsedURL.String())
-		// cmd := exec.Command("open", parsedURL.String()) // for mac os i think i gotta use this
-		// cmd := exec.Command("rundll32", "url.dll,FileProtocolHandler", parsedURL.String()) // and this one for windows
+	linkList := tview.NewList()
+	linkList.SetBackgroundColor(tcell.Color(tcell.ColorValues[0x000000]))
+	linkList.SetMainTextColor(tcell.ColorGreen)
+	linkList.SetSecondaryTextColor(tcell.ColorGray)
+	linkList.SetSelectedTextColor(tcell.ColorBlack)
+	linkList.SetSelectedBackgroundColor(tcell.ColorGreen)
+	linkList.ShowSecondaryText(false)
+	linkList.SetBorder(true)
+	linkList.SetBorderStyle(tcell.StyleDefault.Foreground(tcell.ColorGreen))
+	linkList.SetTitle("Links (Enter/o: open | y: copy | A: add as feed | i: view image | ESC: close)")
+	linkList.SetTitleColor(tcell.ColorGreen)
+
+	linkFlex := tview.NewFlex().
+		AddItem(nil, 0, 1, false).
+		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
+			AddItem(nil, 0, 1, false).
+			AddItem(linkList, 0, 3, true).
+			AddItem(nil, 0, 1, false),
+			0, 4, true).
+		AddItem(nil, 0, 1, false)
+	pages.AddPage("links", linkFlex, true, false)
 
-		return cmd.Start()
+	closeLinkList := func() {
+		pages.HidePage("links")
+		app.SetFocus(contentView)
+	}
 
+	showLinkList := func() {
+		if len(currentLinks) == 0 {
+			statusBar.SetText("No links in this article")
+			resetStatusBarMsg(5)
+			return
+		}
+		linkList.Clear()
+		for i, link := range currentLinks {
+			link := link
+			text := fmt.Sprintf("%d. %s", i+1, link)
+			if currentImages[i+1] {
+				text += " (image)"
+			}
+			linkList.AddItem(text, "", 0, func() {
+				closeLinkList()
+				linkAction('o', link)
+			})
+		}
+		pages.ShowPage("links")
+		app.SetFocus(linkList)
 	}
 
+	linkList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEsc {
+			closeLinkList()
+			return nil
+		}
+		switch event.Rune() {
+		case 'j':
+			return tcell.NewEventKey(tcell.KeyDown, 0, tcell.ModNone)
+		case 'k':
+			return tcell.NewEventKey(tcell.KeyUp, 0, tcell.ModNone)
+		case 'o', 'y', 'A', 'i':
+			index := linkList.GetCurrentItem()
+			if index >= 0 && index < len(currentLinks) {
+				closeLinkList()
+				linkAction(event.Rune(), currentLinks[index])
+			}
+			return nil
+		}
+		return event
+	})
+
+	// typing a number before o/y/A picks a link without opening the list,
+	// "3o" opens the third link
+	linkNumber := 0
+
 	contentView.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if r := event.Rune(); r >= '0' && r <= '9' && event.Modifiers() == tcell.ModNone {
+			linkNumber = linkNumber*10 + int(r-'0')
+			if linkNumber > 0 && linkNumber <= len(currentLinks) {
+				statusBar.SetText(fmt.Sprintf("Link %d: %s (o: open | y: copy | A: add as feed | i: view image)", linkNumber, currentLinks[linkNumber-1]))
+			} else {
+				statusBar.SetText(fmt.Sprintf("No link %d", linkNumber))
+			}
+			return nil
+		}
+		if number := linkNumber; number > 0 {
+			linkNumber = 0
+			switch event.Rune() {
+			case 'o', 'y', 'A', 'i':
+				if number <= len(currentLinks) {
+					linkAction(event.Rune(), currentLinks[number-1])
+				} else {
+					statusBar.SetText(fmt.Sprintf("No link %d", number))
+					resetStatusBarMsg(5)
+				}
+				return nil
+			}
+			statusBar.SetText(defaultStatusBarMsg)
+		}
+		if event.Rune() == 'L' {
+			showLinkList()
+			return nil
+		}
+
+		switch event.Rune() {
+		case 'D':
+			if currentItem == nil || len(currentItem.Enclosures) == 0 {
+				statusBar.SetText("No media to download in this item")
+				resetStatusBarMsg(5)
+				return nil
+			}
+			var auth *models.FeedAuth
+			if feed := feedByURL(currentItem.FeedURL); feed != nil {
+				auth = feed.Auth
+			}
+			for _, enclosure := range currentItem.Enclosures {
+				if err := downloads.Enqueue(enclosure.URL, auth); err != nil {
+					statusBar.SetText("Error: " + err.Error())
+					resetStatusBarMsg(5)
+				} else {
+					statusBar.SetText(fmt.Sprintf("Queued %s", services.FileName(enclosure.URL)))
+				}
+			}
+			return nil
+		case 'p':
+			if currentItem == nil || len(currentItem.Enclosures) == 0 {
+				statusBar.SetText("No media to play in this item")
+				resetStatusBarMsg(5)
+				return nil
+			}
+			// downloaded files are played from disk, otherwise it's streamed
+			enclosureUrl := currentItem.Enclosures[0].URL
+			target := enclosureUrl
+			if downloads.Downloaded(enclosureUrl) {
+				target = downloads.PathFor(enclosureUrl)
+			}
+			if err := urlOpener.OpenWith(cfg.Player, target); err != nil {
+				statusBar.SetText("Error playing media: " + err.Error())
+			} else {
+				statusBar.SetText("Playing " + services.FileName(enclosureUrl))
+			}
+			resetStatusBarMsg(5)
+			return nil
+		case 'e':
+			if currentItem == nil {
+				statusBar.SetText("No article to load")
+				resetStatusBarMsg(5)
+				return nil
+			}
+			loadFullArticle(*currentItem)
+			return nil
+		case 'y', 'Y', 'c':
+			if currentItem == nil {
+				statusBar.SetText("No article to copy from")
+				resetStatusBarMsg(5)
+				return nil
+			}
+
+			var text, what string
+			switch event.Rune() {
+			case 'y':
+				text, what = currentItem.Link, "link"
+			case 'Y':
+				text, what = fmt.Sprintf("[%s](%s)", currentItem.Title, currentItem.Link), "markdown link"
+			case 'c':
+				text, what = contentView.GetText(true), "article text"
+			}
+			if strings.TrimSpace(text) == "" {
+				statusBar.SetText(fmt.Sprintf("No %s to copy", what))
+			} else if err := clipboard.Copy(screen, text); err != nil {
+				statusBar.SetText(fmt.Sprintf("Error copying %s: %v", what, err))
+			} else {
+				statusBar.SetText(fmt.Sprintf("Copied %s to the clipboard", what))
+			}
+			resetStatusBarMsg(5)
+			return nil
+		}
+
 		if event.Key() == tcell.KeyCtrlO {
 			if currentItem != nil && currentItem.Link != "" {
 				err := openURL(currentItem.Link)
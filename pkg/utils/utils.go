@@ -3,7 +3,9 @@ package utils
 import (
 	"fmt"
 	"os"
+	"strings"
 	"sync"
+	"time"
 )
 
 type Logger struct {
@@ -48,3 +50,66 @@ func (l *Logger) Close() {
 		l.file.Close()
 	}
 }
+
+// feeds in the wild use all kinds of date formats, these are the ones i've seen the most
+var dateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339,
+	time.RFC3339Nano,
+	time.RFC822Z,
+	time.RFC822,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"Mon, 02 Jan 2006 15:04 -0700",
+	"2 Jan 2006 15:04:05 -0700",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// ParseDate tries every known layout, returning the zero time if none matches
+func ParseDate(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// FuzzyMatch reports whether text contains query, either as a substring or
+// with its letters in order (so "gblg" matches "go blog"), ignoring case
+func FuzzyMatch(text, query string) bool {
+	text = strings.ToLower(text)
+	query = strings.ToLower(query)
+	if strings.Contains(text, query) {
+		return true
+	}
+
+	remaining := []rune(query)
+	for _, r := range text {
+		if len(remaining) == 0 {
+			break
+		}
+		if r == remaining[0] {
+			remaining = remaining[1:]
+		}
+	}
+	return len(remaining) == 0
+}
+
+// FormatSize turns a size in bytes into something readable, like 12.3 MB
+func FormatSize(bytes int64) string {
+	const unit = 1024
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "KMGTPE"[exp])
+}